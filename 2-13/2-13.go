@@ -1,120 +1,31 @@
 /*
-Утилита cut
-Реализовать утилиту, которая считывает входные данные (STDIN) и разбивает каждую строку по заданному разделителю, после чего выводит определённые поля (колонки).
+Утилита зеркалирования сайтов
+Рекурсивно скачивает сайт в духе wget -m: страницы и их ресурсы, с переписыванием ссылок для просмотра без сети, возобновлением прерванного обхода, WARC и проверкой ссылок (-spider).
 
-Аналог команды cut с поддержкой флагов:
-
--f "fields" — указание номеров полей (колонок), которые нужно вывести. Номера через запятую, можно диапазоны.
-Например: «-f 1,3-5» — вывести 1-й и с 3-го по 5-й столбцы.
-
--d "delimiter" — использовать другой разделитель (символ). По умолчанию разделитель — табуляция ('\t').
-
--s – (separated) только строки, содержащие разделитель. Если флаг указан, то строки без разделителя игнорируются (не выводятся).
-
-Программа должна корректно парсить аргументы, поддерживать различные комбинации (например, несколько отдельных полей и диапазонов), учитывать, что номера полей могут выходить за границы (в таком случае эти поля просто игнорируются).
-
-Стоит обратить внимание на эффективность при обработке больших файлов. Все стандартные требования по качеству кода и тестам также применимы.
+Обход реализован в пакете mirror, здесь — разбор флагов, выбор хранилища и вывод отчётов.
+Утилита cut, описанная раньше в этом файле, находится в каталоге cut.
 */
 
 package main
 
 import (
-	_ "bytes"
-	"crypto/sha256"
+	"compress/gzip"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
-)
-
-// Config содержит конфигурацию программы
-type Config struct {
-	URL           string
-	MaxDepth      int
-	MaxWorkers    int
-	OutputDir     string
-	SameDomain    bool
-	Timeout       time.Duration
-	UserAgent     string
-	VisitedURLs   *sync.Map
-	DownloadQueue chan DownloadTask
-	WaitGroup     sync.WaitGroup
-	Client        *http.Client
-}
 
-// DownloadTask представляет задачу на скачивание
-type DownloadTask struct {
-	URL   string
-	Depth int
-	Type  string // html, css, js, image, other
-}
-
-// HTMLParser упрощенный парсер HTML
-type HTMLParser struct {
-	Content []byte
-	Pos     int
-}
-
-// NewHTMLParser создает новый парсер
-func NewHTMLParser(content []byte) *HTMLParser {
-	return &HTMLParser{Content: content, Pos: 0}
-}
-
-// FindAll находит все значения атрибутов по тегу и атрибуту
-func (p *HTMLParser) FindAll(tag, attribute string) []string {
-	var results []string
-	content := string(p.Content)
-	tagStart := "<" + tag
-	tagLen := len(tagStart)
-
-	for i := 0; i < len(content); i++ {
-		if i+tagLen <= len(content) && strings.EqualFold(content[i:i+tagLen], tagStart) {
-			// Нашли начало тега
-			j := i + tagLen
-			for j < len(content) && content[j] != '>' {
-				j++
-			}
-			if j < len(content) {
-				tagContent := content[i : j+1]
-
-				// Ищем атрибут
-				attrStart := attribute + "=\""
-				attrIdx := strings.Index(strings.ToLower(tagContent), attrStart)
-				if attrIdx == -1 {
-					// Попробуем с одинарными кавычками
-					attrStart = attribute + "='"
-					attrIdx = strings.Index(strings.ToLower(tagContent), attrStart)
-					if attrIdx == -1 {
-						i = j
-						continue
-					}
-				}
-
-				start := attrIdx + len(attrStart)
-				end := start
-				for end < len(tagContent) && tagContent[end] != '"' && tagContent[end] != '\'' {
-					end++
-				}
-
-				if end < len(tagContent) {
-					href := tagContent[start:end]
-					if href != "" && !strings.HasPrefix(href, "#") &&
-						!strings.HasPrefix(strings.ToLower(href), "javascript:") {
-						results = append(results, href)
-					}
-				}
-			}
-			i = j
-		}
-	}
-	return results
-}
+	"github.com/ZnNr/wb-level2/2-13/mirror"
+)
 
 func main() {
 	var (
@@ -123,7 +34,41 @@ func main() {
 		maxWorkers = flag.Int("workers", 5, "Количество параллельных загрузчиков")
 		outputDir  = flag.String("output", "./mirror", "Директория для сохранения")
 		timeout    = flag.Int("timeout", 30, "Таймаут запросов в секундах")
+		spanHosts  = flag.Bool("span-hosts", false, "Переходить по ссылкам на другие хосты")
+		domains    = flag.String("domains", "", "Разрешённые домены через запятую (с поддоменами)")
+		exclDoms   = flag.String("exclude-domains", "", "Запрещённые домены через запятую")
+		noParent   = flag.Bool("no-parent", false, "Не подниматься выше каталога стартового URL")
+		acceptRe   = flag.String("accept-regex", "", "Скачивать только URL, подходящие под регулярное выражение")
+		rejectRe   = flag.String("reject-regex", "", "Не скачивать URL, подходящие под регулярное выражение")
+		accept     = flag.String("A", "", "Разрешённые расширения файлов через запятую")
+		reject     = flag.String("R", "", "Запрещённые расширения файлов через запятую")
+		sitemap    = flag.Bool("sitemap", false, "Добавить страницы из sitemap.xml и robots.txt")
+		warcFile   = flag.String("warc-file", "", "Записать обмены в NAME.warc.gz с индексом NAME.cdx")
+		convert    = flag.Bool("convert-links", true, "Переписать ссылки для просмотра без сети")
+		requisites = flag.Bool("p", false, "Скачать только страницу и всё нужное для её отображения (картинки, CSS, скрипты, шрифты, фреймы) с любых хостов")
+		maxFile    = flag.String("max-file-size", "", "Максимальный размер файла (например, 100M)")
+		quota      = flag.String("quota", "", "Общий лимит скачанных данных (например, 1G)")
+		tries      = flag.Int("tries", 3, "Число попыток при временных ошибках")
+		waitRetry  = flag.Int("waitretry", 10, "Максимальная пауза между попытками в секундах, в том числе по Retry-After")
+		report     = flag.String("report", "", "Файл отчёта о неудачных загрузках (по умолчанию <output>/failed-urls.txt)")
+		loadCookie = flag.String("load-cookies", "", "Загрузить куки из файла cookies.txt (формат Netscape)")
+		saveCookie = flag.String("save-cookies", "", "Сохранить куки в файл cookies.txt после обхода")
+		user       = flag.String("user", "", "Имя пользователя для Basic-авторизации")
+		password   = flag.String("password", "", "Пароль для Basic-авторизации")
+		userAgent  = flag.String("user-agent", mirror.DefaultUserAgent, "Значение заголовка User-Agent")
+		limitRate  = flag.String("limit-rate", "", "Общий лимит скорости загрузки в байтах/с (например, 200k)")
+		wait       = flag.Float64("wait", 0, "Пауза между запросами к одному хосту в секундах")
+		randomWait = flag.Bool("random-wait", false, "Случайная пауза от 0.5 до 1.5 значения -wait")
+		perHost    = flag.Int("max-per-host", 0, "Максимум одновременных загрузок с одного хоста (0 — без ограничения)")
+		spider     = flag.Bool("spider", false, "Только проверить ссылки (HEAD, при необходимости GET), ничего не сохраняя")
+		spiderFmt  = flag.String("spider-format", "text", "Формат отчёта -spider: text, json или junit")
+		spiderOut  = flag.String("spider-report", "", "Файл отчёта -spider (по умолчанию stdout)")
+		tarFile    = flag.String("tar", "", "Сохранить зеркало в tar-архив вместо директории (.tar.gz и .tgz сжимаются)")
+		singleFile = flag.String("single-file", "", "Сохранить страницу со всеми ресурсами в один файл (включает -p)")
+		singleFmt  = flag.String("single-file-format", "", "Формат -single-file: mhtml или html (по умолчанию по расширению файла)")
+		headers    headerList
 	)
+	flag.Var(&headers, "header", "Дополнительный заголовок \"Имя: значение\" (можно повторять)")
 	flag.Parse()
 
 	if *urlStr == "" {
@@ -133,456 +78,334 @@ func main() {
 	}
 
 	// Парсинг URL для проверки
-	_, err := url.Parse(*urlStr)
+	startURL, err := url.Parse(*urlStr)
 	if err != nil {
 		fmt.Printf("Ошибка парсинга URL: %v\n", err)
 		os.Exit(1)
 	}
 
-	config := &Config{
-		URL:         *urlStr,
-		MaxDepth:    *maxDepth,
-		MaxWorkers:  *maxWorkers,
-		OutputDir:   *outputDir,
-		SameDomain:  true,
-		Timeout:     time.Duration(*timeout) * time.Second,
-		UserAgent:   "Go-Wget/1.0",
-		VisitedURLs: &sync.Map{},
-		Client: &http.Client{
-			Timeout: time.Duration(*timeout) * time.Second,
-		},
-		DownloadQueue: make(chan DownloadTask, 1000),
-	}
-
-	// Создаем выходную директорию
-	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
-		fmt.Printf("Ошибка создания директории: %v\n", err)
+	scope, err := mirror.NewScopePolicy(startURL, *spanHosts, *noParent,
+		*domains, *exclDoms, *accept, *reject, *acceptRe, *rejectRe)
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Начинаем скачивание %s (глубина: %d)\n", config.URL, config.MaxDepth)
-	fmt.Printf("Сохранение в: %s\n", config.OutputDir)
-
-	// Запускаем воркеры
-	for i := 0; i < config.MaxWorkers; i++ {
-		config.WaitGroup.Add(1)
-		go worker(config, i)
+	jar, err := mirror.NewCookieJar()
+	if err != nil {
+		fmt.Printf("Ошибка создания хранилища кук: %v\n", err)
+		os.Exit(1)
 	}
-
-	// Добавляем начальную задачу
-	config.DownloadQueue <- DownloadTask{
-		URL:   config.URL,
-		Depth: 0,
-		Type:  "html",
+	if *loadCookie != "" {
+		if err := jar.Load(*loadCookie); err != nil {
+			fmt.Printf("Ошибка загрузки кук: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// Ожидаем завершения всех задач
-	config.WaitGroup.Wait()
-	close(config.DownloadQueue)
-
-	fmt.Println("Скачивание завершено!")
-}
-
-// worker обрабатывает задачи скачивания
-func worker(config *Config, id int) {
-	defer config.WaitGroup.Done()
-
-	for task := range config.DownloadQueue {
-		fmt.Printf("[Воркер %d] Скачивание: %s (глубина: %d)\n", id, task.URL, task.Depth)
-
-		// Проверяем, не скачивали ли мы уже этот URL
-		if _, visited := config.VisitedURLs.Load(task.URL); visited {
-			continue
-		}
-		config.VisitedURLs.Store(task.URL, true)
+	maxFileSize, err := mirror.ParseSize(*maxFile)
+	if err != nil {
+		fmt.Printf("Ошибка в -max-file-size: %v\n", err)
+		os.Exit(1)
+	}
+	quotaSize, err := mirror.ParseSize(*quota)
+	if err != nil {
+		fmt.Printf("Ошибка в -quota: %v\n", err)
+		os.Exit(1)
+	}
+	rateLimit, err := mirror.ParseSize(*limitRate)
+	if err != nil {
+		fmt.Printf("Ошибка в -limit-rate: %v\n", err)
+		os.Exit(1)
+	}
+	if *spider && !isSpiderFormat(*spiderFmt) {
+		fmt.Printf("Ошибка: неизвестный формат отчёта %q\n", *spiderFmt)
+		os.Exit(1)
+	}
+	if *singleFmt != "" && *singleFmt != string(mirror.SingleFileMHTML) && *singleFmt != string(mirror.SingleFileHTML) {
+		fmt.Printf("Ошибка: неизвестный формат -single-file %q\n", *singleFmt)
+		os.Exit(1)
+	}
+	if *singleFile != "" && (*spider || *tarFile != "") {
+		fmt.Println("Ошибка: -single-file несовместим с -spider и -tar")
+		os.Exit(1)
+	}
+	if *spider && *warcFile != "" {
+		fmt.Println("Ошибка: -spider несовместим с -warc-file")
+		os.Exit(1)
+	}
 
-		// Скачиваем ресурс
-		content, contentType, err := downloadResource(config, task.URL)
+	opts := mirror.Options{
+		URL:            *urlStr,
+		MaxDepth:       *maxDepth,
+		Workers:        *maxWorkers,
+		Scope:          scope,
+		ConvertLinks:   *convert,
+		Sitemap:        *sitemap,
+		PageRequisites: *requisites,
+		Timeout:        time.Duration(*timeout) * time.Second,
+		UserAgent:      *userAgent,
+		Headers:        headers.header,
+		AuthUser:       *user,
+		AuthPassword:   *password,
+		Cookies:        jar,
+		MaxFileSize:    maxFileSize,
+		Quota:          quotaSize,
+		Tries:          *tries,
+		WaitRetry:      time.Duration(*waitRetry) * time.Second,
+		MaxPerHost:     *perHost,
+		Wait:           time.Duration(*wait * float64(time.Second)),
+		RandomWait:     *randomWait,
+		LimitRate:      rateLimit,
+		Spider:         *spider,
+		Progress:       os.Stderr,
+	}
+
+	reportFile := *report
+	var tarStorage *mirror.TarStorage
+	var tarOut io.WriteCloser
+	switch {
+	case *spider:
+		// Проверка ссылок ничего не пишет на диск и не возобновляется
+	case *singleFile != "":
+		// Страница с ресурсами собирается в памяти и пишется одним файлом
+		opts.PageRequisites = true
+		opts.ConvertLinks = false
+	case *tarFile != "":
+		// Архив собирается целиком за запуск, состояние не сохраняется
+		tarOut, err = createArchive(*tarFile)
 		if err != nil {
-			fmt.Printf("[Воркер %d] Ошибка скачивания %s: %v\n", id, task.URL, err)
-			continue
+			fmt.Printf("Ошибка создания архива: %v\n", err)
+			os.Exit(1)
 		}
-
-		// Сохраняем файл
-		localPath, err := saveResource(config, task.URL, content, contentType)
+		tarStorage, err = mirror.NewTarStorage(tarOut)
 		if err != nil {
-			fmt.Printf("[Воркер %d] Ошибка сохранения %s: %v\n", id, task.URL, err)
-			continue
+			fmt.Printf("Ошибка создания архива: %v\n", err)
+			os.Exit(1)
 		}
-
-		// Если это HTML и не достигнута максимальная глубина - парсим ссылки
-		if isHTMLContent(contentType) && task.Depth < config.MaxDepth {
-			baseURL, _ := url.Parse(task.URL)
-			links, resources := parseHTMLSimple(content, baseURL)
-
-			// Добавляем новые задачи в очередь
-			for _, link := range links {
-				if shouldDownload(config, link, baseURL) {
-					if _, visited := config.VisitedURLs.Load(link); !visited {
-						config.DownloadQueue <- DownloadTask{
-							URL:   link,
-							Depth: task.Depth + 1,
-							Type:  "html",
-						}
-					}
-				}
-			}
-
-			// Добавляем ресурсы (CSS, JS, изображения)
-			for _, res := range resources {
-				if _, visited := config.VisitedURLs.Load(res); !visited {
-					config.DownloadQueue <- DownloadTask{
-						URL:   res,
-						Depth: task.Depth + 1,
-						Type:  "resource",
-					}
-				}
-			}
-
-			// Обновляем HTML с локальными путями
-			updatedHTML := replaceLinksSimple(content, baseURL, localPath)
-			if err := os.WriteFile(localPath, updatedHTML, 0644); err != nil {
-				fmt.Printf("[Воркер %d] Ошибка обновления HTML: %v\n", id, err)
-			}
+		opts.Storage = tarStorage
+	default:
+		// Создаем выходную директорию
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			fmt.Printf("Ошибка создания директории: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Storage = mirror.NewDirStorage(*outputDir)
+		opts.StateFile = filepath.Join(*outputDir, mirror.StateFileName)
+		// Временные файлы на том же диске переносятся в зеркало без копирования
+		opts.TempDir = *outputDir
+		if reportFile == "" {
+			reportFile = filepath.Join(*outputDir, "failed-urls.txt")
 		}
 	}
-}
 
-// downloadResource скачивает ресурс по URL
-func downloadResource(config *Config, urlStr string) ([]byte, string, error) {
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return nil, "", err
+	if *warcFile != "" {
+		// В warcinfo тот же User-Agent, что уходит в запросах
+		software := opts.UserAgent
+		if software == "" {
+			software = mirror.DefaultUserAgent
+		}
+		opts.WARC, err = mirror.NewWARCWriter(*warcFile, software)
+		if err != nil {
+			fmt.Printf("Ошибка создания WARC: %v\n", err)
+			os.Exit(1)
+		}
 	}
-	req.Header.Set("User-Agent", config.UserAgent)
 
-	resp, err := config.Client.Do(req)
+	crawler, err := mirror.New(opts)
 	if err != nil {
-		return nil, "", err
+		fmt.Printf("Ошибка: %v\n", err)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("HTTP статус: %d", resp.StatusCode)
+	if *spider {
+		fmt.Fprintf(os.Stderr, "Проверка ссылок %s (глубина: %d)\n", *urlStr, *maxDepth)
+	} else {
+		fmt.Printf("Начинаем скачивание %s (глубина: %d)\n", *urlStr, *maxDepth)
+		if *tarFile != "" {
+			fmt.Printf("Сохранение в архив: %s\n", *tarFile)
+		} else if *singleFile != "" {
+			fmt.Printf("Сохранение в файл: %s\n", *singleFile)
+		} else {
+			fmt.Printf("Сохранение в: %s\n", *outputDir)
+		}
 	}
 
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", err
-	}
+	// SIGINT/SIGTERM останавливают обход, фронтир сохраняется для продолжения
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	runErr := crawler.Run(ctx)
+	stop()
 
-	return content, resp.Header.Get("Content-Type"), nil
-}
-
-// saveResource сохраняет ресурс в файл
-func saveResource(config *Config, urlStr string, content []byte, contentType string) (string, error) {
-	parsedURL, err := url.Parse(urlStr)
-	if err != nil {
-		return "", err
+	if opts.WARC != nil {
+		if err := opts.WARC.Close(); err != nil {
+			fmt.Printf("Ошибка записи WARC: %v\n", err)
+		}
 	}
 
-	// Создаем путь для сохранения
-	path := parsedURL.Path
-	if path == "" || strings.HasSuffix(path, "/") {
-		path = path + "index.html"
+	if *saveCookie != "" {
+		if err := jar.Save(*saveCookie); err != nil {
+			fmt.Printf("Ошибка сохранения кук: %v\n", err)
+		}
 	}
 
-	// Убираем начальный слэш
-	if strings.HasPrefix(path, "/") {
-		path = path[1:]
+	if errors.Is(runErr, context.Canceled) {
+		if tarStorage != nil {
+			tarStorage.Close()
+			tarOut.Close()
+		}
+		fmt.Fprintln(os.Stderr, "Прервано, состояние сохранено. Повторный запуск продолжит обход.")
+		os.Exit(130)
 	}
-
-	// Создаем директории
-	fullPath := filepath.Join(config.OutputDir, parsedURL.Hostname(), path)
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
+	if runErr != nil {
+		fmt.Printf("Ошибка: %v\n", runErr)
 	}
 
-	// Сохраняем файл
-	if err := os.WriteFile(fullPath, content, 0644); err != nil {
-		return "", err
+	if *spider {
+		os.Exit(writeSpiderReport(crawler.SpiderReport(), *spiderOut, *spiderFmt))
 	}
 
-	return fullPath, nil
-}
-
-// parseHTMLSimple парсит HTML и извлекает ссылки и ресурсы
-func parseHTMLSimple(content []byte, baseURL *url.URL) ([]string, []string) {
-	var links, resources []string
-	contentStr := string(content)
-
-	// Ищем ссылки в <a> тегах
-	links = append(links, extractLinks(contentStr, "a", "href", baseURL)...)
-
-	// Ищем ресурсы
-	resources = append(resources, extractLinks(contentStr, "link", "href", baseURL)...)
-	resources = append(resources, extractLinks(contentStr, "script", "src", baseURL)...)
-	resources = append(resources, extractLinks(contentStr, "img", "src", baseURL)...)
-	resources = append(resources, extractLinks(contentStr, "iframe", "src", baseURL)...)
-	resources = append(resources, extractLinks(contentStr, "embed", "src", baseURL)...)
-	resources = append(resources, extractLinks(contentStr, "source", "src", baseURL)...)
-
-	// Убираем дубликаты
-	links = removeDuplicates(links)
-	resources = removeDuplicates(resources)
-
-	return links, resources
-}
-
-// extractLinks извлекает ссылки из HTML
-func extractLinks(content, tag, attr string, baseURL *url.URL) []string {
-	var results []string
-	tagStart := "<" + strings.ToLower(tag)
-	attrPattern := strings.ToLower(attr) + "=\""
-
-	pos := 0
-	for {
-		// Ищем начало тега
-		tagPos := strings.Index(strings.ToLower(content[pos:]), tagStart)
-		if tagPos == -1 {
-			break
+	if *singleFile != "" && runErr == nil {
+		if err := writeSingleFile(crawler, *singleFile, *singleFmt); err != nil {
+			fmt.Printf("Ошибка записи %s: %v\n", *singleFile, err)
+			os.Exit(1)
 		}
-		tagPos += pos
+	}
 
-		// Ищем конец тега
-		endPos := strings.Index(content[tagPos:], ">")
-		if endPos == -1 {
-			break
+	if tarStorage != nil {
+		if err := tarStorage.Close(); err != nil {
+			fmt.Printf("Ошибка записи архива: %v\n", err)
 		}
-		endPos += tagPos
-
-		// Извлекаем атрибут
-		tagContent := content[tagPos:endPos]
-		attrPos := strings.Index(strings.ToLower(tagContent), attrPattern)
-		if attrPos != -1 {
-			start := tagPos + attrPos + len(attrPattern)
-			end := start
-			for end < len(content) && content[end] != '"' {
-				end++
-			}
-			if end < len(content) {
-				href := content[start:end]
-				if isValidLink(href) {
-					absoluteURL := resolveURL(href, baseURL)
-					if absoluteURL != "" {
-						results = append(results, absoluteURL)
-					}
-				}
-			}
+		if err := tarOut.Close(); err != nil {
+			fmt.Printf("Ошибка записи архива: %v\n", err)
 		}
+	}
 
-		pos = endPos + 1
-		if pos >= len(content) {
-			break
+	// Отчёт пишется всегда, чтобы не оставался устаревший от прошлого запуска
+	if reportFile != "" {
+		failures := crawler.Failures()
+		if err := failures.WriteFile(reportFile); err != nil {
+			fmt.Printf("Ошибка записи отчёта: %v\n", err)
+		} else if n := failures.Len(); n > 0 {
+			fmt.Printf("Не удалось скачать %d URL, отчёт: %s\n", n, reportFile)
 		}
 	}
 
-	return results
-}
-
-// isValidLink проверяет, является ли ссылка валидной для скачивания
-func isValidLink(link string) bool {
-	return link != "" &&
-		!strings.HasPrefix(link, "#") &&
-		!strings.HasPrefix(strings.ToLower(link), "javascript:") &&
-		!strings.HasPrefix(strings.ToLower(link), "mailto:")
+	fmt.Println("Скачивание завершено!")
 }
 
-// resolveURL преобразует относительный URL в абсолютный
-func resolveURL(href string, baseURL *url.URL) string {
-	if href == "" {
-		return ""
+// writeSingleFile пишет страницу в один файл. Без явного формата .mht
+// и .mhtml дают MHTML, остальные расширения — HTML с data: URI.
+func writeSingleFile(crawler *mirror.Crawler, name, format string) error {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".mht", ".mhtml":
+			format = string(mirror.SingleFileMHTML)
+		default:
+			format = string(mirror.SingleFileHTML)
+		}
 	}
 
-	parsed, err := url.Parse(href)
+	f, err := os.Create(name)
 	if err != nil {
-		return ""
+		return err
 	}
-
-	// Абсолютные URL
-	if parsed.IsAbs() {
-		return parsed.String()
+	if err := crawler.WriteSingleFile(f, mirror.SingleFileFormat(format)); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
 	}
-
-	// Относительные URL
-	resolved := baseURL.ResolveReference(parsed)
-	return resolved.String()
+	return f.Close()
 }
 
-// removeDuplicates удаляет дубликаты из среза
-func removeDuplicates(slice []string) []string {
-	seen := make(map[string]bool)
-	result := []string{}
-
-	for _, item := range slice {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
-		}
-	}
-
-	return result
-}
-
-// shouldDownload проверяет, нужно ли скачивать ссылку
-func shouldDownload(config *Config, urlStr string, baseURL *url.URL) bool {
-	parsed, err := url.Parse(urlStr)
+// createArchive создает файл архива; для .tar.gz и .tgz поверх него пишется gzip
+func createArchive(name string) (io.WriteCloser, error) {
+	f, err := os.Create(name)
 	if err != nil {
-		return false
+		return nil, err
 	}
-
-	// Проверяем схему
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return false
+	if !strings.HasSuffix(name, ".gz") && !strings.HasSuffix(name, ".tgz") {
+		return f, nil
 	}
-
-	// Если включен режим только того же домена
-	if config.SameDomain && parsed.Hostname() != baseURL.Hostname() {
-		return false
-	}
-
-	return true
+	return &gzipFile{Writer: gzip.NewWriter(f), file: f}, nil
 }
 
-// isHTMLContent проверяет, является ли контент HTML
-func isHTMLContent(contentType string) bool {
-	return strings.Contains(strings.ToLower(contentType), "text/html") ||
-		strings.Contains(strings.ToLower(contentType), "application/xhtml+xml")
+// gzipFile сжатый файл: Close дописывает gzip и закрывает файл
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
 }
 
-// replaceLinksSimple заменяет ссылки в HTML на локальные пути
-func replaceLinksSimple(content []byte, baseURL *url.URL, localPath string) []byte {
-	contentStr := string(content)
-
-	// Заменяем ссылки в разных тегах
-	tags := []struct {
-		tag  string
-		attr string
-	}{
-		{"a", "href"},
-		{"link", "href"},
-		{"script", "src"},
-		{"img", "src"},
-		{"iframe", "src"},
-		{"embed", "src"},
-		{"source", "src"},
+func (g *gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.file.Close()
+		return err
 	}
-
-	for _, t := range tags {
-		contentStr = replaceLinksInTag(contentStr, t.tag, t.attr, baseURL)
-	}
-
-	return []byte(contentStr)
+	return g.file.Close()
 }
 
-// replaceLinksInTag заменяет ссылки в конкретном теге
-func replaceLinksInTag(content, tag, attr string, baseURL *url.URL) string {
-	tagStart := "<" + strings.ToLower(tag)
-	attrPattern := strings.ToLower(attr) + "=\""
-
-	var result strings.Builder
-	pos := 0
-
-	for {
-		// Ищем начало тега
-		tagPos := strings.Index(strings.ToLower(content[pos:]), tagStart)
-		if tagPos == -1 {
-			result.WriteString(content[pos:])
-			break
-		}
-		tagPos += pos
-
-		// Пишем все до тега
-		result.WriteString(content[pos:tagPos])
-
-		// Ищем конец тега
-		endPos := strings.Index(content[tagPos:], ">")
-		if endPos == -1 {
-			result.WriteString(content[tagPos:])
-			break
-		}
-		endPos += tagPos
-
-		// Извлекаем и заменяем атрибут
-		tagContent := content[tagPos:endPos]
-		attrPos := strings.Index(strings.ToLower(tagContent), attrPattern)
-		if attrPos != -1 {
-			start := attrPos + len(attrPattern)
-			end := start
-			for end < len(tagContent) && tagContent[end] != '"' {
-				end++
-			}
-			if end < len(tagContent) {
-				href := tagContent[start:end]
-				if isValidLink(href) {
-					absoluteURL := resolveURL(href, baseURL)
-					if absoluteURL != "" {
-						// Преобразуем в локальный путь
-						localPath := urlToLocalPath(absoluteURL, baseURL)
-						// Заменяем ссылку
-						newTag := tagContent[:start] + localPath + tagContent[end:]
-						result.WriteString(newTag)
-					} else {
-						result.WriteString(tagContent)
-					}
-				} else {
-					result.WriteString(tagContent)
-				}
-			} else {
-				result.WriteString(tagContent)
-			}
-		} else {
-			result.WriteString(tagContent)
-		}
-
-		pos = endPos
-		if pos >= len(content) {
-			break
+// writeSpiderReport выводит отчёт -spider и возвращает код выхода:
+// 8, как у wget, если найдены битые ссылки
+func writeSpiderReport(report *mirror.SpiderReport, path, format string) int {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка создания отчёта: %v\n", err)
+			return 1
 		}
+		defer f.Close()
+		out = f
 	}
 
-	return result.String()
-}
-
-// urlToLocalPath преобразует URL в локальный путь
-func urlToLocalPath(urlStr string, baseURL *url.URL) string {
-	parsed, err := url.Parse(urlStr)
+	broken, err := report.Write(out, format)
 	if err != nil {
-		return urlStr
+		fmt.Fprintf(os.Stderr, "Ошибка записи отчёта: %v\n", err)
+		return 1
 	}
-
-	// Если другой домен - оставляем как есть
-	if parsed.Hostname() != baseURL.Hostname() {
-		return urlStr
+	if broken > 0 {
+		fmt.Fprintf(os.Stderr, "Найдено битых ссылок: %d\n", broken)
+		return 8
 	}
+	return 0
+}
 
-	// Преобразуем путь
-	path := parsed.Path
-	if path == "" || strings.HasSuffix(path, "/") {
-		path = path + "index.html"
+// isSpiderFormat проверяет название формата отчёта
+func isSpiderFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "text", "json", "junit":
+		return true
 	}
+	return false
+}
 
-	// Убираем начальный слэш
-	if strings.HasPrefix(path, "/") {
-		path = "." + path
-	}
+// headerList значение повторяемого флага -header "Имя: значение"
+type headerList struct {
+	header http.Header
+}
 
-	// Добавляем query если есть
-	if parsed.RawQuery != "" {
-		path = path + "?" + parsed.RawQuery
+func (h *headerList) String() string {
+	if h.header == nil {
+		return ""
 	}
-
-	// Добавляем fragment если есть
-	if parsed.Fragment != "" {
-		path = path + "#" + parsed.Fragment
+	var parts []string
+	for name, values := range h.header {
+		for _, v := range values {
+			parts = append(parts, name+": "+v)
+		}
 	}
-
-	return path
+	return strings.Join(parts, ", ")
 }
 
-// hashContent создает хеш содержимого
-func hashContent(content []byte) string {
-	hash := sha256.Sum256(content)
-	return fmt.Sprintf("%x", hash[:16])
+// Set разбирает очередной заголовок
+func (h *headerList) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("ожидается \"Имя: значение\", получено %q", value)
+	}
+	if h.header == nil {
+		h.header = make(http.Header)
+	}
+	h.header.Add(name, strings.TrimSpace(val))
+	return nil
 }
@@ -0,0 +1,592 @@
+/*
+Утилита cut
+Реализовать утилиту, которая считывает входные данные (STDIN) и разбивает каждую строку по заданному разделителю, после чего выводит определённые поля (колонки).
+
+Аналог команды cut с поддержкой флагов:
+
+-f "fields" — указание номеров полей (колонок), которые нужно вывести. Номера через запятую, можно диапазоны.
+Например: «-f 1,3-5» — вывести 1-й и с 3-го по 5-й столбцы.
+
+-d "delimiter" — использовать другой разделитель (символ). По умолчанию разделитель — табуляция ('\t').
+
+-s – (separated) только строки, содержащие разделитель. Если флаг указан, то строки без разделителя игнорируются (не выводятся).
+
+Программа должна корректно парсить аргументы, поддерживать различные комбинации (например, несколько отдельных полей и диапазонов), учитывать, что номера полей могут выходить за границы (в таком случае эти поля просто игнорируются).
+
+Стоит обратить внимание на эффективность при обработке больших файлов. Все стандартные требования по качеству кода и тестам также применимы.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// cutOptions хранит параметры командной строки
+type cutOptions struct {
+	fields     string
+	columns    string
+	bytes      string
+	chars      string
+	delimiter  string
+	outDelim   string
+	separated  bool
+	noSplit    bool
+	complement bool
+	csv        bool
+	header     bool
+	whitespace bool
+	regexDelim string
+
+	// Заданы ли флаги явно: у -d и -output-delimiter есть значения по умолчанию
+	delimiterSet bool
+	outDelimSet  bool
+}
+
+func main() {
+	var opts cutOptions
+
+	flag.StringVar(&opts.fields, "f", "", "Select only these fields, e.g. 1,3-5")
+	flag.StringVar(&opts.columns, "F", "", "Select CSV columns by header name, e.g. name,age (implies -csv; output keeps input order)")
+	flag.StringVar(&opts.bytes, "b", "", "Select only these bytes")
+	flag.StringVar(&opts.chars, "c", "", "Select only these characters (UTF-8)")
+	flag.StringVar(&opts.delimiter, "d", "\t", "Use DELIM instead of TAB for field delimiter")
+	flag.BoolVar(&opts.whitespace, "w", false, "Split fields on runs of whitespace, ignoring leading blanks")
+	flag.StringVar(&opts.regexDelim, "regex-delimiter", "", "Split fields on matches of the regular expression RE")
+	flag.StringVar(&opts.outDelim, "output-delimiter", "", "Use STRING as the output delimiter (default: the input delimiter for -f, TAB for -w and -regex-delimiter, none for -b and -c)")
+	flag.BoolVar(&opts.separated, "s", false, "Do not print lines not containing delimiters")
+	flag.BoolVar(&opts.noSplit, "n", false, "With -b: do not split multibyte characters")
+	flag.BoolVar(&opts.complement, "complement", false, "Complement the set of selected bytes, characters or fields")
+	flag.BoolVar(&opts.csv, "csv", false, "Parse fields as RFC 4180 CSV (delimiter defaults to a comma) and quote output as needed")
+	flag.BoolVar(&opts.header, "header", true, "With -csv: print the header row (the first record)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s OPTION... [FILE]...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "d":
+			opts.delimiterSet = true
+		case "output-delimiter":
+			opts.outDelimSet = true
+		}
+	})
+
+	c, err := newCutter(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"} // STDIN
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	exitCode := 0
+	for _, filename := range files {
+		if err := cutFile(c, filename, out); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exitCode = 1
+		}
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exitCode = 1
+	}
+
+	os.Exit(exitCode)
+}
+
+// cutMode что считается позицией в списке
+type cutMode int
+
+const (
+	modeFields cutMode = iota // -f: поля между разделителями
+	modeBytes                 // -b: байты
+	modeChars                 // -c: символы UTF-8
+)
+
+// cutter вырезает выбранные позиции из строк
+type cutter struct {
+	mode      cutMode
+	list      rangeList
+	delim     []byte         // разделитель полей на входе
+	sep       *regexp.Regexp // разделитель -w и -regex-delimiter вместо delim
+	trimLead  bool           // -w: пробелы в начале строки не отделяют поле
+	outDelim  []byte         // разделитель на выходе: между полями или между диапазонами -b/-c
+	separated bool
+	noSplit   bool
+
+	// Режим CSV: поля разбираются и выводятся по RFC 4180
+	csv        bool
+	columns    []string // -F: имена колонок, номера берутся из заголовка каждого файла
+	complement bool     // для -F дополнение строится после поиска колонок
+	header     bool     // выводить первую запись
+}
+
+// newCutter проверяет сочетание флагов и готовит cutter
+func newCutter(opts cutOptions) (*cutter, error) {
+	c := &cutter{
+		separated:  opts.separated,
+		noSplit:    opts.noSplit,
+		csv:        opts.csv || opts.columns != "",
+		complement: opts.complement,
+		header:     opts.header,
+	}
+
+	var list string
+	lists := 0
+	if opts.fields != "" {
+		c.mode, list = modeFields, opts.fields
+		lists++
+	}
+	if opts.columns != "" {
+		// Имена разбираются как строка CSV, чтобы допускать запятые в кавычках
+		columns, err := csv.NewReader(strings.NewReader(opts.columns)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("invalid column list: %w", err)
+		}
+		c.mode, c.columns = modeFields, columns
+		lists++
+	}
+	if opts.bytes != "" {
+		c.mode, list = modeBytes, opts.bytes
+		lists++
+	}
+	if opts.chars != "" {
+		c.mode, list = modeChars, opts.chars
+		lists++
+	}
+	switch {
+	case lists == 0:
+		return nil, errors.New("you must specify a list of bytes, characters, or fields")
+	case lists > 1:
+		return nil, errors.New("only one type of list may be specified")
+	}
+
+	if c.mode != modeFields && opts.separated {
+		return nil, errors.New("-s is only allowed when operating on fields")
+	}
+	if c.mode != modeBytes && opts.noSplit {
+		return nil, errors.New("-n is only allowed with -b")
+	}
+	if c.mode != modeFields && c.csv {
+		return nil, errors.New("-csv is only allowed when operating on fields")
+	}
+
+	delimiters := 0
+	for _, set := range []bool{opts.delimiterSet, opts.whitespace, opts.regexDelim != ""} {
+		if set {
+			delimiters++
+		}
+	}
+	if delimiters > 1 {
+		return nil, errors.New("only one of -d, -w and -regex-delimiter may be specified")
+	}
+	if delimiters > 0 && c.mode != modeFields {
+		return nil, errors.New("-d, -w and -regex-delimiter are only allowed when operating on fields")
+	}
+	if c.csv && (opts.whitespace || opts.regexDelim != "") {
+		return nil, errors.New("-csv takes a single-character delimiter, not -w or -regex-delimiter")
+	}
+
+	if c.columns == nil {
+		var err error
+		if c.list, err = parseList(list); err != nil {
+			return nil, err
+		}
+		if opts.complement {
+			c.list = c.list.complement()
+		}
+	}
+
+	switch {
+	case c.mode != modeFields:
+	case opts.whitespace || opts.regexDelim != "":
+		expr := opts.regexDelim
+		if opts.whitespace {
+			expr = whitespaceRun
+			c.trimLead = true
+		}
+		var err error
+		if c.sep, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid regex delimiter: %w", err)
+		}
+		// Пустое совпадение не продвигало бы разбор строки. Проверяется
+		// само выражение: \b или ^ не совпадают с "", но совпадают пусто внутри строки
+		parsed, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex delimiter: %w", err)
+		}
+		if matchesEmpty(parsed) {
+			return nil, errors.New("the regex delimiter must not match an empty string")
+		}
+		// Совпадения разной длины не годятся в выходной разделитель
+		c.outDelim = []byte{'\t'}
+	default:
+		if c.csv && !opts.delimiterSet {
+			opts.delimiter = ","
+		}
+		if utf8.RuneCountInString(opts.delimiter) != 1 {
+			return nil, errors.New("the delimiter must be a single character")
+		}
+		c.delim = []byte(opts.delimiter)
+		c.outDelim = c.delim
+	}
+	if opts.outDelimSet {
+		c.outDelim = []byte(opts.outDelim)
+	}
+
+	if c.csv {
+		if !validCSVDelim(string(c.delim)) {
+			return nil, errors.New("invalid CSV delimiter")
+		}
+		if !validCSVDelim(string(c.outDelim)) {
+			return nil, errors.New("with -csv the output delimiter must be a single character other than a quote or newline")
+		}
+	}
+	return c, nil
+}
+
+// matchesEmpty сообщает, может ли выражение совпасть с пустой подстрокой
+// хотя бы в одной позиции какой-нибудь строки
+func matchesEmpty(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune) == 0
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL, syntax.OpNoMatch:
+		return false
+	case syntax.OpStar, syntax.OpQuest:
+		return true
+	case syntax.OpRepeat:
+		return re.Min == 0 || matchesEmpty(re.Sub[0])
+	case syntax.OpCapture, syntax.OpPlus:
+		return matchesEmpty(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !matchesEmpty(sub) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if matchesEmpty(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	// Пустое выражение, якоря ^ $ \A \z и границы слов \b \B
+	return true
+}
+
+// validCSVDelim проверяет, что разделитель допустим для encoding/csv
+func validCSVDelim(delim string) bool {
+	r, size := utf8.DecodeRuneInString(delim)
+	return size == len(delim) && size > 0 && r != utf8.RuneError &&
+		r != '"' && r != '\r' && r != '\n'
+}
+
+// cutFile обрабатывает один файл, "-" означает STDIN
+func cutFile(c *cutter, filename string, w *bufio.Writer) error {
+	if filename == "-" {
+		return c.cut(os.Stdin, w)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.cut(f, w)
+}
+
+// cut читает поток построчно: в памяти держится только текущая строка
+func (c *cutter) cut(r io.Reader, w *bufio.Writer) error {
+	if c.csv {
+		return c.cutCSV(r, w)
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			c.cutLine(bytes.TrimSuffix(line, []byte{'\n'}), w)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// cutLine выводит выбранные позиции одной строки
+func (c *cutter) cutLine(line []byte, w *bufio.Writer) {
+	switch c.mode {
+	case modeFields:
+		c.cutFields(line, w)
+	case modeBytes:
+		c.cutBytes(line, w)
+	case modeChars:
+		c.cutChars(line, w)
+	}
+}
+
+// cutFields выводит выбранные поля через выходной разделитель.
+// Строка без разделителя выводится целиком, а с -s пропускается.
+func (c *cutter) cutFields(line []byte, w *bufio.Writer) {
+	rest := line
+	if c.trimLead {
+		rest = bytes.TrimLeft(rest, whitespaceChars)
+	}
+	field, rest, found := c.nextField(rest)
+	if !found {
+		if !c.separated {
+			w.Write(line)
+			w.WriteByte('\n')
+		}
+		return
+	}
+
+	last := c.list.last()
+	first := true
+	for n := 1; ; n++ {
+		if c.list.contains(n) {
+			if !first {
+				w.Write(c.outDelim)
+			}
+			w.Write(field)
+			first = false
+		}
+		// Номера за последним полем просто игнорируются
+		if !found || n >= last {
+			break
+		}
+		field, rest, found = c.nextField(rest)
+	}
+	w.WriteByte('\n')
+}
+
+const (
+	// whitespaceRun разделитель -w: любая последовательность пробельных символов
+	whitespaceRun = `[[:space:]]+`
+	// whitespaceChars те же символы для отбрасывания начальных пробелов
+	whitespaceChars = " \t\n\v\f\r"
+)
+
+// nextField отделяет первое поле строки от остатка; found == false,
+// если разделителя в строке нет
+func (c *cutter) nextField(s []byte) (field, rest []byte, found bool) {
+	if c.sep == nil {
+		return bytes.Cut(s, c.delim)
+	}
+	loc := c.sep.FindIndex(s)
+	if loc == nil {
+		return s, nil, false
+	}
+	return s[:loc[0]], s[loc[1]:], true
+}
+
+// cutCSV выводит выбранные колонки CSV по одной записи за раз. Поля
+// в кавычках могут содержать разделители и переводы строк; на выходе
+// поля заключаются в кавычки только при необходимости. Пустые строки,
+// которые encoding/csv пропускает, выводятся как в обычном режиме:
+// как строки без разделителя, а с -s не выводятся.
+func (c *cutter) cutCSV(r io.Reader, w *bufio.Writer) error {
+	lc := &lineCounter{r: r}
+	cr := csv.NewReader(lc)
+	cr.Comma, _ = utf8.DecodeRune(c.delim)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	cw := csv.NewWriter(w)
+	cw.Comma, _ = utf8.DecodeRune(c.outDelim)
+
+	// blank выводит n пропущенных пустых строк
+	blank := func(n int) error {
+		for ; n > 0 && !c.separated; n-- {
+			if err := cw.Write([]string{""}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	list := c.list
+	var selected []string
+	lastLine := 0 // последняя строка ввода, занятая предыдущей записью
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			// Пустые строки в конце файла видны только по числу переводов строк
+			if err := blank(lc.lines - lastLine); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		if err := blank(line - lastLine - 1); err != nil {
+			return err
+		}
+		// Поле в кавычках может занимать несколько строк
+		lastLine, _ = cr.FieldPos(len(record) - 1)
+		lastLine += strings.Count(record[len(record)-1], "\n")
+
+		if first {
+			if c.columns != nil {
+				if list, err = c.columnList(record); err != nil {
+					return err
+				}
+			}
+			if !c.header {
+				continue
+			}
+		}
+
+		// Запись без разделителя, как строка в cut: целиком или пропуск с -s
+		if len(record) < 2 {
+			if !c.separated {
+				if err := cw.Write(record); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		selected = selected[:0]
+		for i, field := range record {
+			if list.contains(i + 1) {
+				selected = append(selected, field)
+			}
+		}
+		if err := cw.Write(selected); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// lineCounter считает переводы строк в прочитанных данных
+type lineCounter struct {
+	r     io.Reader
+	lines int
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+	lc.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// columnList находит номера колонок -F по записи заголовка
+func (c *cutter) columnList(header []string) (rangeList, error) {
+	var list rangeList
+	for _, name := range c.columns {
+		idx := -1
+		for i, column := range header {
+			if column == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("column %q not found in header", name)
+		}
+		list = append(list, span{idx + 1, idx + 1})
+	}
+
+	list = list.merge()
+	if c.complement {
+		list = list.complement()
+	}
+	return list, nil
+}
+
+// cutBytes выводит выбранные байты. С -n границы диапазонов сдвигаются
+// к началу символа: многобайтовый символ выводится целиком, если выбран
+// его последний байт, иначе не выводится вовсе.
+func (c *cutter) cutBytes(line []byte, w *bufio.Writer) {
+	first := true
+	for _, sp := range c.list {
+		if sp.lo > len(line) {
+			break
+		}
+		start, end := sp.lo-1, min(sp.hi, len(line))
+		if c.noSplit {
+			start, end = runeStart(line, start), runeStart(line, end)
+		}
+		c.writeSegment(line[start:end], &first, w)
+	}
+	w.WriteByte('\n')
+}
+
+// cutChars выводит выбранные символы; некорректные байты UTF-8
+// считаются отдельными символами
+func (c *cutter) cutChars(line []byte, w *bufio.Writer) {
+	pos, count := 0, 0 // байтовое смещение символа с номером count+1
+	advance := func(n int) {
+		for count < n && pos < len(line) {
+			_, size := utf8.DecodeRune(line[pos:])
+			pos += size
+			count++
+		}
+	}
+
+	first := true
+	for _, sp := range c.list {
+		advance(sp.lo - 1)
+		if pos >= len(line) {
+			break
+		}
+		start := pos
+		advance(sp.hi)
+		c.writeSegment(line[start:pos], &first, w)
+	}
+	w.WriteByte('\n')
+}
+
+// writeSegment выводит диапазон -b или -c, отделяя его от предыдущего
+// выходным разделителем
+func (c *cutter) writeSegment(seg []byte, first *bool, w *bufio.Writer) {
+	if len(seg) == 0 {
+		return
+	}
+	if !*first {
+		w.Write(c.outDelim)
+	}
+	w.Write(seg)
+	*first = false
+}
+
+// runeStart возвращает начало символа, которому принадлежит байт i
+func runeStart(line []byte, i int) int {
+	for back := 0; back < utf8.UTFMax-1 && i > 0 && i < len(line) && !utf8.RuneStart(line[i]); back++ {
+		i--
+	}
+	return i
+}
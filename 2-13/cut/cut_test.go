@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+// runCut прогоняет in через cutter с параметрами opts
+func runCut(t *testing.T, opts cutOptions, in string) (string, error) {
+	t.Helper()
+	c, err := newCutter(opts)
+	if err != nil {
+		t.Fatalf("newCutter(%+v): %v", opts, err)
+	}
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	err = c.cut(strings.NewReader(in), w)
+	w.Flush()
+	return out.String(), err
+}
+
+// cutTest вход и ожидаемый вывод для набора параметров
+type cutTest struct {
+	opts cutOptions
+	in   string
+	want string
+}
+
+// checkCut прогоняет таблицу тестов
+func checkCut(t *testing.T, tests []cutTest) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := runCut(t, tt.opts, tt.in)
+		if err != nil {
+			t.Errorf("%+v on %q: %v", tt.opts, tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v on %q = %q, want %q", tt.opts, tt.in, got, tt.want)
+		}
+	}
+}
+
+// Номера за концом строки не дают ни полей, ни лишних разделителей
+func TestCutPastEnd(t *testing.T) {
+	checkCut(t, []cutTest{
+		{cutOptions{fields: "2,5-", delimiter: "\t"}, "a\tb\tc\n", "b\n"},
+		{cutOptions{fields: "4-", delimiter: "\t"}, "a\tb\tc\n", "\n"},
+		{cutOptions{fields: "1,9", delimiter: ":"}, "a:b\nc\n", "a\nc\n"},
+		{cutOptions{fields: "3", delimiter: ":", separated: true}, "a:b\nc\n", "\n"},
+		{cutOptions{fields: "1", delimiter: ":", complement: true}, "a:b:c\n", "b:c\n"},
+		{cutOptions{bytes: "2-3,10-"}, "abcd\n", "bc\n"},
+		{cutOptions{bytes: "5-"}, "abcd\nabcdef\n", "\nef\n"},
+		{cutOptions{chars: "2,4-9"}, "привет\nпр\n", "рвет\nр\n"},
+	})
+}
+
+func TestCutBytesAndChars(t *testing.T) {
+	checkCut(t, []cutTest{
+		// -b режет по байтам, в том числе посреди символа
+		{cutOptions{bytes: "1-3"}, "привет\n", "п\xd1\n"},
+		{cutOptions{bytes: "1,3"}, "abc\n", "ac\n"},
+		{cutOptions{bytes: "3,1"}, "abc\n", "ac\n"},
+		{cutOptions{bytes: "-2,2-3"}, "abcd\n", "abc\n"},
+		// -n не разрезает многобайтовые символы
+		{cutOptions{bytes: "1-3", noSplit: true}, "привет\n", "п\n"},
+		{cutOptions{bytes: "1-4", noSplit: true}, "привет\n", "пр\n"},
+		{cutOptions{bytes: "2", noSplit: true}, "привет\n", "п\n"},
+		{cutOptions{bytes: "1", noSplit: true}, "привет\n", "\n"},
+		{cutOptions{chars: "1-2"}, "привет\n", "пр\n"},
+		{cutOptions{chars: "2-"}, "a\xffb\n", "\xffb\n"},
+		// Строка без перевода в конце тоже выводится
+		{cutOptions{chars: "1"}, "ab\ncd", "a\nc\n"},
+		{cutOptions{chars: "1"}, "", ""},
+		{cutOptions{chars: "1"}, "\n\n", "\n\n"},
+	})
+}
+
+func TestCutComplement(t *testing.T) {
+	checkCut(t, []cutTest{
+		{cutOptions{bytes: "2-3", complement: true}, "abcde\n", "ade\n"},
+		{cutOptions{bytes: "1-", complement: true}, "abc\n", "\n"},
+		{cutOptions{chars: "1,3", complement: true}, "привет\n", "рвет\n"},
+		{cutOptions{fields: "2", delimiter: ",", complement: true}, "a,b,c\nd\n", "a,c\nd\n"},
+		{cutOptions{fields: "2-", delimiter: ",", complement: true}, "a,b,c\n", "a\n"},
+	})
+}
+
+func TestCutOutputDelimiter(t *testing.T) {
+	checkCut(t, []cutTest{
+		{cutOptions{fields: "1,3", delimiter: ",", outDelim: " | ", outDelimSet: true}, "a,b,c\n", "a | c\n"},
+		{cutOptions{fields: "1-", delimiter: ",", outDelim: "", outDelimSet: true}, "a,b,c\n", "abc\n"},
+		// Для -b и -c разделитель ставится между диапазонами, а не между байтами
+		{cutOptions{bytes: "1-2,4", outDelim: ":", outDelimSet: true}, "abcd\n", "ab:d\n"},
+		{cutOptions{bytes: "1-2,3", outDelim: ":", outDelimSet: true}, "abcd\n", "abc\n"},
+		{cutOptions{chars: "1,3-", outDelim: "—", outDelimSet: true}, "привет\n", "п—ивет\n"},
+		{cutOptions{chars: "1,9", outDelim: ":", outDelimSet: true}, "ab\n", "a\n"},
+	})
+}
+
+func TestNewCutterErrors(t *testing.T) {
+	tests := []cutOptions{
+		{},
+		{fields: "1", bytes: "1"},
+		{bytes: "1", chars: "1"},
+		{bytes: "1", separated: true},
+		{chars: "1", noSplit: true},
+		{fields: "1", noSplit: true},
+		{bytes: "1", delimiter: ",", delimiterSet: true},
+		{fields: "1", delimiter: "::"},
+		{fields: "1", delimiter: ""},
+		{bytes: "0"},
+		{chars: "3-1"},
+	}
+	for _, opts := range tests {
+		if _, err := newCutter(opts); err == nil {
+			t.Errorf("newCutter(%+v) accepted", opts)
+		}
+	}
+}
+
+func TestCutCSV(t *testing.T) {
+	csvOpts := func(o cutOptions) cutOptions {
+		o.csv, o.header = true, true
+		return o
+	}
+	checkCut(t, []cutTest{
+		// Разделитель и переводы строк внутри кавычек не делят поле
+		{csvOpts(cutOptions{fields: "2"}), "\"a,b\",c\n", "c\n"},
+		{csvOpts(cutOptions{fields: "1"}), "\"a,b\",c\n", "\"a,b\"\n"},
+		{csvOpts(cutOptions{fields: "1,3"}), "\"x\ny\",z,w\n", "\"x\ny\",w\n"},
+		{csvOpts(cutOptions{fields: "1"}), "\"x\r\ny\",z\r\n", "\"x\ny\"\n"},
+		// На выходе кавычки только где нужны
+		{csvOpts(cutOptions{fields: "1"}), "\"plain\",z\n", "plain\n"},
+		{csvOpts(cutOptions{fields: "1"}), "\"say \"\"hi\"\"\",x\n", "\"say \"\"hi\"\"\"\n"},
+		{csvOpts(cutOptions{fields: "1-", outDelim: ";", outDelimSet: true}), "a;b,c\n", "\"a;b\";c\n"},
+		{csvOpts(cutOptions{fields: "1-", delimiter: ";", delimiterSet: true}), "a,b;c\n", "a,b;c\n"},
+		{csvOpts(cutOptions{fields: "2", complement: true}), "a,b,c\n", "a,c\n"},
+		// Запись без разделителя — целиком, с -s пропускается
+		{csvOpts(cutOptions{fields: "2"}), "a,b\nsolo\n", "b\nsolo\n"},
+		{csvOpts(cutOptions{fields: "2", separated: true}), "a,b\nsolo\n", "b\n"},
+		// Пустые строки сохраняются, как в обычном режиме
+		{csvOpts(cutOptions{fields: "2"}), "\na,b\n\n\"x\ny\",z\n\r\n\nq,r\n\n", "\nb\n\nz\n\n\nr\n\n"},
+		{csvOpts(cutOptions{fields: "2", separated: true}), "\na,b\n\nq,r\n\n", "b\nr\n"},
+		{csvOpts(cutOptions{fields: "2"}), "a,b\n\nq,r", "b\n\nr\n"},
+	})
+}
+
+func TestCutCSVColumns(t *testing.T) {
+	checkCut(t, []cutTest{
+		// Колонки выводятся в порядке файла
+		{cutOptions{columns: "c,a", header: true}, "a,b,c\n1,2,3\n", "a,c\n1,3\n"},
+		{cutOptions{columns: "b", header: false}, "a,b,c\n1,2,3\n", "2\n"},
+		{cutOptions{columns: `"x,y"`, header: true}, "\"x,y\",z\n1,2\n", "\"x,y\"\n1\n"},
+		{cutOptions{columns: "b", complement: true, header: true}, "a,b,c\n1,2,3\n", "a,c\n1,3\n"},
+		{cutOptions{columns: "a,c", complement: true, header: false}, "a,b,c\n1,2,3\n4,5,6\n", "2\n5\n"},
+		{cutOptions{columns: "a", header: true}, "", ""},
+	})
+
+	for _, in := range []string{"a,b\n1,2\n", "\n\n"} {
+		if _, err := runCut(t, cutOptions{columns: "missing", header: true}, "a,b\n"+in); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+			t.Errorf("unknown column on %q: %v", in, err)
+		}
+	}
+	if _, err := runCut(t, cutOptions{fields: "1", csv: true}, "a,\"b\nc"); err == nil {
+		t.Error("unterminated quote accepted")
+	}
+	if _, err := runCut(t, cutOptions{fields: "1", csv: true}, "a\"b,c\n"); err == nil {
+		t.Error("bare quote accepted")
+	}
+}
+
+func TestNewCutterCSVErrors(t *testing.T) {
+	tests := []cutOptions{
+		{bytes: "1", csv: true},
+		{columns: "a", chars: "1"},
+		{fields: "1", csv: true, whitespace: true},
+		{fields: "1", csv: true, regexDelim: ";+"},
+		{fields: "1", csv: true, delimiter: `"`, delimiterSet: true},
+		{fields: "1", csv: true, outDelim: "\n", outDelimSet: true},
+		{fields: "1", csv: true, outDelim: "::", outDelimSet: true},
+		{columns: `"a`},
+	}
+	for _, opts := range tests {
+		if _, err := newCutter(opts); err == nil {
+			t.Errorf("newCutter(%+v) accepted", opts)
+		}
+	}
+}
+
+func TestNextField(t *testing.T) {
+	tests := []struct {
+		opts        cutOptions
+		in          string
+		field, rest string
+		found       bool
+	}{
+		{cutOptions{fields: "1", delimiter: ":"}, "a:b:c", "a", "b:c", true},
+		{cutOptions{fields: "1", delimiter: ":"}, ":b", "", "b", true},
+		{cutOptions{fields: "1", delimiter: ":"}, "abc", "abc", "", false},
+		{cutOptions{fields: "1", delimiter: "→"}, "a→b", "a", "b", true},
+		{cutOptions{fields: "1", whitespace: true}, "a \t b c", "a", "b c", true},
+		{cutOptions{fields: "1", whitespace: true}, "abc", "abc", "", false},
+		{cutOptions{fields: "1", regexDelim: `\s*[,;]\s*`}, "a , b;c", "a", "b;c", true},
+		{cutOptions{fields: "1", regexDelim: `-+`}, "--a", "", "a", true},
+	}
+	for _, tt := range tests {
+		c, err := newCutter(tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		field, rest, found := c.nextField([]byte(tt.in))
+		if string(field) != tt.field || string(rest) != tt.rest || found != tt.found {
+			t.Errorf("%+v: nextField(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.opts, tt.in, field, rest, found, tt.field, tt.rest, tt.found)
+		}
+	}
+}
+
+func TestCutWhitespace(t *testing.T) {
+	w := func(fields string) cutOptions { return cutOptions{fields: fields, whitespace: true} }
+	checkCut(t, []cutTest{
+		{w("2"), "a  b\t\tc\n", "b\n"},
+		{w("1,3"), "a  b\t\tc\n", "a\tc\n"},
+		// Пробелы в начале строки не дают пустого первого поля
+		{w("1"), "   a b\n", "a\n"},
+		{w("2"), "\t a b\n", "b\n"},
+		// Строка из одних пробелов и строка без разделителя выводятся как есть
+		{w("2"), "   \n", "   \n"},
+		{w("2"), "  solo\n", "  solo\n"},
+		{cutOptions{fields: "2", whitespace: true, separated: true}, "  solo\na b\n", "b\n"},
+		// Пробелы в конце дают пустое последнее поле
+		{w("2-"), "a b  \n", "b\t\n"},
+		{w("2"), "a\vb\fc\r\n", "b\n"},
+		{cutOptions{fields: "1-", whitespace: true, outDelim: ",", outDelimSet: true}, " a  b c\n", "a,b,c\n"},
+		{cutOptions{fields: "2", whitespace: true, complement: true}, "a b c\n", "a\tc\n"},
+	})
+}
+
+func TestCutRegexDelimiter(t *testing.T) {
+	re := func(fields, expr string) cutOptions { return cutOptions{fields: fields, regexDelim: expr} }
+	checkCut(t, []cutTest{
+		{re("2", `[,;]`), "a,b;c\n", "b\n"},
+		{re("1,3", `\s*\|\s*`), "a | b|c\n", "a\tc\n"},
+		// В отличие от -w, совпадение в начале строки отделяет пустое поле
+		{re("1", `\s+`), "  a b\n", "\n"},
+		{re("2", `\s+`), "  a b\n", "a\n"},
+		{re("2", `x+`), "axxxbxc\n", "b\n"},
+		// Номера за последним полем игнорируются
+		{re("2,7-", `:`), "a:b:c\n", "b\n"},
+		{re("5-", `:`), "a:b:c\n", "\n"},
+		{re("1,9", `:`), "a:b\nc\n", "a\nc\n"},
+		// -s пропускает строки без совпадений
+		{cutOptions{fields: "2", regexDelim: `\d+`, separated: true}, "a1b\nnone\nc22d\n", "b\nd\n"},
+		{cutOptions{fields: "2", regexDelim: `\d+`}, "a1b\nnone\n", "b\nnone\n"},
+		{cutOptions{fields: "1-", regexDelim: `\d+`, outDelim: "-", outDelimSet: true}, "a1b22c\n", "a-b-c\n"},
+	})
+}
+
+func TestNewCutterRegexErrors(t *testing.T) {
+	tests := []cutOptions{
+		// Выражения, совпадающие с пустой строкой
+		{fields: "1", regexDelim: `x*`},
+		{fields: "1", regexDelim: `^`},
+		{fields: "1", regexDelim: `\b`},
+		{fields: "1", regexDelim: `a|`},
+		{fields: "1", regexDelim: `(?m)$`},
+		{fields: "1", regexDelim: `\B`},
+		{fields: "1", regexDelim: `a{0,2}`},
+		{fields: "1", regexDelim: `(a*)+`},
+		{fields: "1", regexDelim: `(`},
+		{fields: "1", whitespace: true, regexDelim: `,`},
+		{fields: "1", whitespace: true, delimiter: ",", delimiterSet: true},
+		{fields: "1", regexDelim: `,`, delimiter: ",", delimiterSet: true},
+		{bytes: "1", whitespace: true},
+		{chars: "1", regexDelim: `,`},
+	}
+	for _, opts := range tests {
+		if _, err := newCutter(opts); err == nil {
+			t.Errorf("newCutter(%+v) accepted", opts)
+		}
+	}
+	for _, expr := range []string{`a{1,2}`, `\s+\b`, `(a|b)+`, `^a|b$`, `x*y`} {
+		if _, err := newCutter(cutOptions{fields: "1", regexDelim: expr}); err != nil {
+			t.Errorf("regex %s rejected: %v", expr, err)
+		}
+	}
+	_, err := newCutter(cutOptions{fields: "1", regexDelim: `x*`})
+	if err == nil || !strings.Contains(err.Error(), "empty string") {
+		t.Errorf("empty-match error: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// span диапазон позиций [lo, hi], нумерация с 1.
+// Позиции — номера полей, байтов или символов в зависимости от режима.
+// Открытый справа диапазон "N-" хранится с hi = math.MaxInt.
+type span struct {
+	lo, hi int
+}
+
+// rangeList список выбранных позиций: диапазоны отсортированы по lo
+// и не пересекаются, поэтому каждая позиция выводится один раз и по порядку,
+// как в cut. Один и тот же разбор используется для -f, -b и -c.
+type rangeList []span
+
+// parseList разбирает список вида "1,3-5,-2,7-": номера и диапазоны через
+// запятую. Пересекающиеся и соседние диапазоны объединяются.
+func parseList(s string) (rangeList, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("empty list")
+	}
+
+	var list rangeList
+	for _, item := range strings.Split(s, ",") {
+		sp, err := parseSpan(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sp)
+	}
+	return list.merge(), nil
+}
+
+// parseSpan разбирает один элемент списка: N, N-M, -M или N-
+func parseSpan(item string) (span, error) {
+	if item == "" || item == "-" {
+		return span{}, fmt.Errorf("invalid range %q", item)
+	}
+
+	loStr, hiStr, isRange := strings.Cut(item, "-")
+	if !isRange {
+		n, err := parseNumber(item)
+		if err != nil {
+			return span{}, err
+		}
+		return span{n, n}, nil
+	}
+
+	sp := span{lo: 1, hi: math.MaxInt}
+	var err error
+	if loStr != "" {
+		if sp.lo, err = parseNumber(loStr); err != nil {
+			return span{}, err
+		}
+	}
+	if hiStr != "" {
+		if sp.hi, err = parseNumber(hiStr); err != nil {
+			return span{}, err
+		}
+	}
+	if sp.lo > sp.hi {
+		return span{}, fmt.Errorf("invalid decreasing range %q", item)
+	}
+	return sp, nil
+}
+
+// parseNumber разбирает позицию; позиции нумеруются с 1
+func parseNumber(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || strings.HasPrefix(s, "+") {
+		return 0, fmt.Errorf("invalid position %q", s)
+	}
+	if n < 1 {
+		return 0, errors.New("positions are numbered from 1")
+	}
+	return n, nil
+}
+
+// merge сортирует диапазоны и объединяет пересекающиеся и соседние
+func (l rangeList) merge() rangeList {
+	sort.Slice(l, func(i, j int) bool { return l[i].lo < l[j].lo })
+
+	merged := l[:0]
+	for _, sp := range l {
+		// Открытый диапазон поглощает всё, что начинается после него
+		n := len(merged)
+		if n > 0 && (merged[n-1].hi == math.MaxInt || sp.lo <= merged[n-1].hi+1) {
+			merged[n-1].hi = max(merged[n-1].hi, sp.hi)
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// complement возвращает позиции, не вошедшие в список (--complement)
+func (l rangeList) complement() rangeList {
+	var result rangeList
+	next := 1
+	for _, sp := range l {
+		if sp.lo > next {
+			result = append(result, span{next, sp.lo - 1})
+		}
+		if sp.hi == math.MaxInt {
+			return result
+		}
+		next = sp.hi + 1
+	}
+	return append(result, span{next, math.MaxInt})
+}
+
+// contains сообщает, выбрана ли позиция n
+func (l rangeList) contains(n int) bool {
+	for _, sp := range l {
+		if n < sp.lo {
+			return false
+		}
+		if n <= sp.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// last возвращает последнюю выбранную позицию: дальше строку
+// можно не разбирать. Для открытого диапазона — math.MaxInt.
+func (l rangeList) last() int {
+	if len(l) == 0 {
+		return 0
+	}
+	return l[len(l)-1].hi
+}
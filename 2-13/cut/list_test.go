@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+const inf = math.MaxInt
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want rangeList
+	}{
+		{"1", rangeList{{1, 1}}},
+		{"3-", rangeList{{3, inf}}},
+		{"-2", rangeList{{1, 2}}},
+		{"1-", rangeList{{1, inf}}},
+		{"2-2", rangeList{{2, 2}}},
+		{" 1 , 3 ", rangeList{{1, 1}, {3, 3}}},
+		// Пересекающиеся и соседние диапазоны объединяются
+		{"1-3,2-5", rangeList{{1, 5}}},
+		{"1-3,4-5", rangeList{{1, 5}}},
+		{"1,2,3", rangeList{{1, 3}}},
+		{"1-2,4-5", rangeList{{1, 2}, {4, 5}}},
+		{"5,1,3", rangeList{{1, 1}, {3, 3}, {5, 5}}},
+		{"2-4,3", rangeList{{2, 4}}},
+		// Открытый диапазон поглощает всё после своего начала
+		{"3-,1,5-7,10", rangeList{{1, 1}, {3, inf}}},
+		{"3-,2", rangeList{{2, inf}}},
+		{"-2,4-", rangeList{{1, 2}, {4, inf}}},
+		{"-3,4-", rangeList{{1, inf}}},
+	}
+	for _, tt := range tests {
+		got, err := parseList(tt.in)
+		if err != nil {
+			t.Errorf("parseList(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseList(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseListErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		" ",
+		"0",
+		"0-3",
+		"-0",
+		"3-1",
+		"-",
+		"1,,2",
+		"1,",
+		"a",
+		"1-a",
+		"+1",
+		"-1-2",
+		"1-2-3",
+		"99999999999999999999",
+	} {
+		if got, err := parseList(in); err == nil {
+			t.Errorf("parseList(%q) = %v, want error", in, got)
+		}
+	}
+}
+
+func TestComplement(t *testing.T) {
+	tests := []struct {
+		in   string
+		want rangeList
+	}{
+		{"1", rangeList{{2, inf}}},
+		{"2", rangeList{{1, 1}, {3, inf}}},
+		{"2-3,5", rangeList{{1, 1}, {4, 4}, {6, inf}}},
+		{"3-", rangeList{{1, 2}}},
+		{"-3", rangeList{{4, inf}}},
+		{"1-", nil},
+	}
+	for _, tt := range tests {
+		list, err := parseList(tt.in)
+		if err != nil {
+			t.Fatalf("parseList(%q): %v", tt.in, err)
+		}
+		if got := list.complement(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("complement(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestContainsAndLast(t *testing.T) {
+	list, err := parseList("2-3,5,8-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range map[int]bool{1: false, 2: true, 3: true, 4: false, 5: true, 6: false, 8: true, 1000: true} {
+		if got := list.contains(n); got != want {
+			t.Errorf("contains(%d) = %v, want %v", n, got, want)
+		}
+	}
+	if got := list.last(); got != inf {
+		t.Errorf("last() = %d, want open", got)
+	}
+
+	list, _ = parseList("4,1-2")
+	if got := list.last(); got != 4 {
+		t.Errorf("last() = %d, want 4", got)
+	}
+	if got := rangeList(nil).last(); got != 0 {
+		t.Errorf("empty last() = %d, want 0", got)
+	}
+}
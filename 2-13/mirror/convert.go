@@ -0,0 +1,140 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// convertLinks переписывает ссылки во всех сохранённых HTML-страницах
+// и таблицах стилей. Запускается после обхода, когда известно, какие
+// ресурсы действительно скачаны.
+func (c *Crawler) convertLinks() {
+	c.state.mu.Lock()
+	pages := make([]ResourceState, 0, len(c.state.Resources))
+	for _, res := range c.state.Resources {
+		if isHTMLContent(res.ContentType) || isCSSContent(res.ContentType) {
+			pages = append(pages, *res)
+		}
+	}
+	c.state.mu.Unlock()
+
+	for _, page := range pages {
+		if err := c.convertPage(page.URL, page.LocalPath, page.ContentType); err != nil {
+			c.progress.Logf("Ошибка преобразования ссылок в %s: %v", page.LocalPath, err)
+		}
+	}
+}
+
+// convertPage переписывает ссылки в одном файле, если они изменились.
+// Страница в другой кодировке переписывается в UTF-8 и сохраняется
+// обратно в исходной кодировке. В таблицах стилей меняются только адреса
+// в url() и @import, остальные байты не трогаются.
+func (c *Crawler) convertPage(pageURL, localPath, contentType string) error {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+
+	f, err := c.storage.Open(localPath)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	updated, err := rewriteResource(content, contentType, func(href string, _ bool) string {
+		return c.convertLink(href, base, localPath)
+	})
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(updated, content) {
+		return nil
+	}
+	return c.storage.Put(localPath, bytes.NewReader(updated))
+}
+
+// rewriteResource заменяет ссылки в HTML или CSS с помощью convert
+// (второй аргумент — ресурс это или переход по <a>). HTML переписывается
+// в UTF-8 и возвращается в исходной кодировке, в CSS меняются только адреса.
+func rewriteResource(content []byte, contentType string, convert func(href string, resource bool) string) ([]byte, error) {
+	if isCSSContent(contentType) {
+		return []byte(replaceCSSLinks(string(content), func(href string) string {
+			return convert(href, true)
+		})), nil
+	}
+
+	enc, name := htmlEncoding(content, contentType)
+	text := toUTF8(content, contentType)
+
+	updated := replaceLinksSimple(string(text), convert)
+	if updated == string(text) {
+		return content, nil
+	}
+	encoded, ok := fromUTF8([]byte(updated), enc, name)
+	if !ok {
+		return nil, fmt.Errorf("ссылки не представимы в кодировке %s", name)
+	}
+	return encoded, nil
+}
+
+// convertLink возвращает ссылку для страницы localPath (URL baseURL):
+// путь относительно её каталога для скачанного ресурса, иначе абсолютный URL.
+// Ссылки на скачанные ресурсы (в том числе с других хостов) становятся
+// относительными, ссылки на нескачанные — абсолютными URL, как в wget -k.
+func (c *Crawler) convertLink(href string, baseURL *url.URL, localPath string) string {
+	target := c.linkTarget(href, baseURL, localPath)
+	if target == nil {
+		return href
+	}
+
+	fragment := ""
+	if target.Fragment != "" {
+		fragment = "#" + target.Fragment
+	}
+
+	filePath, ok := c.paths.Lookup(target.String())
+	if !ok || !c.storage.Exists(filePath) {
+		return target.String()
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(localPath), filePath)
+	if err != nil {
+		return target.String()
+	}
+	return localHref(rel) + fragment
+}
+
+// linkTarget определяет, на какой URL указывает ссылка.
+// Относительная ссылка, уже ведущая на сохранённый файл (страница
+// преобразована в прошлом запуске), сопоставляется с URL этого файла,
+// поэтому повторное преобразование не портит ссылки.
+func (c *Crawler) linkTarget(href string, baseURL *url.URL, localPath string) *url.URL {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return nil
+	}
+	if parsed.IsAbs() || parsed.Host != "" || strings.HasPrefix(parsed.Path, "/") || parsed.Path == "" {
+		return baseURL.ResolveReference(parsed)
+	}
+
+	unescaped, err := url.PathUnescape(parsed.Path)
+	if err == nil {
+		filePath := filepath.Join(filepath.Dir(localPath), filepath.FromSlash(unescaped))
+		if urlStr, ok := c.paths.URLFor(filePath); ok {
+			if target, err := url.Parse(urlStr); err == nil {
+				target.Fragment = parsed.Fragment
+				return target
+			}
+		}
+	}
+
+	return baseURL.ResolveReference(parsed)
+}
@@ -0,0 +1,67 @@
+package mirror
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertLinks(t *testing.T) {
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/":             {Body: `<a href="a.html#sec">a</a> <a href="/dir/page">dir</a> <a href="http://other.invalid/x">ext</a>`},
+		"/a.html":       {Body: `<link rel="stylesheet" href="/style.css"><img src="img.png">`},
+		"/dir/page":     {Body: `<a href="/">home</a> <a href="../deep.html">deep</a> <a href="../missing.html">x</a> <img src="/img.png">`},
+		"/deep.html":    {Body: `deep`},
+		"/img.png":      {Body: "PNG"},
+		"/style.css":    {Body: `@import "dir/more.css"; p { background: url(img.png) }`},
+		"/dir/more.css": {Body: `p { background: url("../img.png") }`},
+	})
+
+	opts := Options{
+		URL:          srv.URL + "/",
+		MaxDepth:     2,
+		ConvertLinks: true,
+		StateFile:    filepath.Join(t.TempDir(), StateFileName),
+	}
+	_, storage := runCrawl(t, opts)
+	host := hostPrefix(srv)
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"index.html", []string{
+			`href="a.html#sec"`,
+			`href="dir/page.html"`,
+			`href="http://other.invalid/x"`,
+		}},
+		{"a.html", []string{`href="style.css"`, `src="img.png"`}},
+		// missing.html не скачан: ссылка становится абсолютной
+		{"dir/page.html", []string{
+			`href="../index.html"`,
+			`href="../deep.html"`,
+			`href="` + srv.URL + `/missing.html"`,
+			`src="../img.png"`,
+		}},
+		{"style.css", []string{`@import "dir/more.css"`, `url(img.png)`}},
+		{"dir/more.css", []string{`url("../img.png")`}},
+	}
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			content := fileString(t, storage, host+"/"+tt.file)
+			for _, want := range tt.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("%s: no %s in %s", tt.file, want, content)
+				}
+			}
+		}
+	}
+	check()
+
+	// Повторный запуск разбирает уже преобразованные страницы
+	// и не портит относительные ссылки
+	opts.Storage = storage
+	runCrawl(t, opts)
+	check()
+}
@@ -0,0 +1,244 @@
+package mirror
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// httpOnlyPrefix префикс строки cookies.txt для HttpOnly-кук
+const httpOnlyPrefix = "#HttpOnly_"
+
+// CookieJar хранилище кук на основе cookiejar, которое дополнительно
+// помнит все установленные куки, чтобы их можно было сохранить в cookies.txt
+// (стандартный cookiejar не позволяет перечислить содержимое).
+type CookieJar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	entries map[string]*cookieEntry // домен|путь|имя → кука
+}
+
+// cookieEntry кука в терминах формата Netscape
+type cookieEntry struct {
+	Domain            string
+	IncludeSubdomains bool
+	Path              string
+	Secure            bool
+	HTTPOnly          bool
+	Expires           time.Time // нулевое значение — сессионная кука
+	Name              string
+	Value             string
+}
+
+// NewCookieJar создает пустое хранилище кук. Список публичных суффиксов
+// не даёт сайту поставить куку на весь домен вроде co.uk.
+func NewCookieJar() (*CookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	return &CookieJar{jar: jar, entries: make(map[string]*cookieEntry)}, nil
+}
+
+// SetCookies реализует http.CookieJar. Для сохранения запоминаются только
+// куки, которые принял cookiejar: кука на чужой домен или на публичный
+// суффикс не отправлялась бы и в cookies.txt не попадает.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		entry := entryFromCookie(u, c)
+		key := entry.Domain + "|" + entry.Path + "|" + entry.Name
+		if c.MaxAge < 0 || (!entry.Expires.IsZero() && entry.Expires.Before(time.Now())) {
+			delete(j.entries, key)
+			continue
+		}
+		if j.accepted(u, entry) {
+			j.entries[key] = entry
+		}
+	}
+}
+
+// accepted проверяет, что cookiejar сохранил куку: она отдаётся
+// для её пути на хосте, который её установил
+func (j *CookieJar) accepted(u *url.URL, e *cookieEntry) bool {
+	scheme := u.Scheme
+	if e.Secure {
+		scheme = "https"
+	}
+	target := &url.URL{Scheme: scheme, Host: u.Host, Path: e.Path}
+	for _, c := range j.jar.Cookies(target) {
+		if c.Name == e.Name && c.Value == e.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Cookies реализует http.CookieJar
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Load читает куки из файла в формате Netscape cookies.txt
+func (j *CookieJar) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("%s:%d: ожидается 7 полей через табуляцию, получено %d", path, lineNum, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s:%d: некорректный срок действия %q", path, lineNum, fields[4])
+		}
+
+		entry := &cookieEntry{
+			Domain:            fields[0],
+			IncludeSubdomains: strings.EqualFold(fields[1], "TRUE"),
+			Path:              fields[2],
+			Secure:            strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly:          httpOnly,
+			Name:              fields[5],
+			Value:             fields[6],
+		}
+		if expires > 0 {
+			entry.Expires = time.Unix(expires, 0)
+		}
+		j.add(entry)
+	}
+	return scanner.Err()
+}
+
+// Save записывает куки в файл в формате Netscape cookies.txt
+func (j *CookieJar) Save(path string) error {
+	j.mu.Lock()
+	entries := make([]*cookieEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		entries = append(entries, e)
+	}
+	j.mu.Unlock()
+
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Domain != entries[b].Domain {
+			return entries[a].Domain < entries[b].Domain
+		}
+		if entries[a].Path != entries[b].Path {
+			return entries[a].Path < entries[b].Path
+		}
+		return entries[a].Name < entries[b].Name
+	})
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	b.WriteString("# Generated by Go-Wget. Edit at your own risk.\n\n")
+	now := time.Now()
+	for _, e := range entries {
+		if !e.Expires.IsZero() && e.Expires.Before(now) {
+			continue
+		}
+		if e.HTTPOnly {
+			b.WriteString(httpOnlyPrefix)
+		}
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.Domain, netscapeBool(e.IncludeSubdomains), e.Path, netscapeBool(e.Secure),
+			expires, e.Name, e.Value)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// add помещает загруженную куку в cookiejar и в список для сохранения
+func (j *CookieJar) add(e *cookieEntry) {
+	host := strings.TrimPrefix(e.Domain, ".")
+	scheme := "http"
+	if e.Secure {
+		scheme = "https"
+	}
+	u := &url.URL{Scheme: scheme, Host: host, Path: e.Path}
+
+	cookie := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Secure:   e.Secure,
+		HttpOnly: e.HTTPOnly,
+		Expires:  e.Expires,
+	}
+	// Атрибут Domain делает куку доменной, без него она привязана к хосту
+	if e.IncludeSubdomains {
+		cookie.Domain = host
+	}
+	j.SetCookies(u, []*http.Cookie{cookie})
+}
+
+// entryFromCookie переводит куку из ответа сервера в запись cookies.txt
+func entryFromCookie(u *url.URL, c *http.Cookie) *cookieEntry {
+	entry := &cookieEntry{
+		Domain:   u.Hostname(),
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HTTPOnly: c.HttpOnly,
+		Name:     c.Name,
+		Value:    c.Value,
+	}
+	if c.Domain != "" {
+		entry.Domain = "." + strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		entry.IncludeSubdomains = true
+	}
+	if entry.Path == "" || !strings.HasPrefix(entry.Path, "/") {
+		// Путь по умолчанию — каталог URL (RFC 6265, 5.1.4)
+		entry.Path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			entry.Path = u.Path[:i]
+		}
+	}
+	switch {
+	case c.MaxAge > 0:
+		entry.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		entry.Expires = c.Expires
+	}
+	return entry
+}
+
+// netscapeBool форматирует логическое значение для cookies.txt
+func netscapeBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
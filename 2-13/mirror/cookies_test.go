@@ -0,0 +1,145 @@
+package mirror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieJarSavesAcceptedCookies(t *testing.T) {
+	jar, err := NewCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://www.example.co.uk/docs/page.html")
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: "example.co.uk", Path: "/", Expires: future},
+		{Name: "secure", Value: "3", Path: "/", Secure: true, HttpOnly: true},
+		// Отклоняются cookiejar: чужой домен и публичный суффикс
+		{Name: "foreign", Value: "4", Domain: "other.com"},
+		{Name: "suffix", Value: "5", Domain: "co.uk"},
+	})
+	// Удаление ранее установленной куки
+	jar.SetCookies(u, []*http.Cookie{{Name: "gone", Value: "6", Path: "/"}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "gone", Value: "", Path: "/", MaxAge: -1}})
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := jar.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")[3:]
+	want := []string{
+		".example.co.uk\tTRUE\t/\tFALSE\t" + strconv.FormatInt(future.Unix(), 10) + "\tdomain\t2",
+		"#HttpOnly_www.example.co.uk\tFALSE\t/\tTRUE\t0\tsecure\t3",
+		"www.example.co.uk\tFALSE\t/docs\tFALSE\t0\thost\t1",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("cookies.txt:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Сохранённый файл загружается в новое хранилище
+	loaded, err := NewCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	secure, _ := url.Parse("https://www.example.co.uk/docs/x")
+	got := make(map[string]string)
+	for _, c := range loaded.Cookies(secure) {
+		got[c.Name] = c.Value
+	}
+	if len(got) != 3 || got["host"] != "1" || got["domain"] != "2" || got["secure"] != "3" {
+		t.Errorf("loaded cookies %v", got)
+	}
+	sub, _ := url.Parse("http://shop.example.co.uk/")
+	if c := loaded.Cookies(sub); len(c) != 1 || c[0].Name != "domain" {
+		t.Errorf("subdomain cookies %v", c)
+	}
+}
+
+func TestCookieJarLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"fields":  "example.com\tFALSE\t/\tFALSE\t0\tname\n",
+		"expires": "example.com\tFALSE\t/\tFALSE\tsoon\tname\tvalue\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0600)
+		jar, _ := NewCookieJar()
+		if err := jar.Load(path); err == nil {
+			t.Errorf("%s: Load accepted %q", name, content)
+		}
+	}
+}
+
+func TestCrawlSendsCookiesAuthAndHeaders(t *testing.T) {
+	var otherRequest *http.Request
+	var pageRequest *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.Host != "" && strings.HasPrefix(r.Host, "localhost"):
+			otherRequest = r.Clone(r.Context())
+		case r.URL.Path == "/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			otherURL := strings.Replace("http://"+r.Host, "127.0.0.1", "localhost", 1)
+			w.Write([]byte(`<a href="page.html">p</a> <img src="` + otherURL + `/pixel.png">`))
+		case r.URL.Path == "/page.html":
+			pageRequest = r.Clone(r.Context())
+		}
+	}))
+	defer srv.Close()
+
+	start, _ := url.Parse(srv.URL + "/")
+	scope, err := NewScopePolicy(start, true, false, "", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCrawl(t, Options{
+		URL:          start.String(),
+		MaxDepth:     1,
+		Workers:      1,
+		Scope:        scope,
+		UserAgent:    "test-agent/3",
+		Headers:      http.Header{"X-Test": {"1"}, "Accept-Language": {"ru"}},
+		AuthUser:     "user",
+		AuthPassword: "secret",
+	})
+
+	if pageRequest == nil || otherRequest == nil {
+		t.Fatalf("page requested: %v, other host requested: %v", pageRequest != nil, otherRequest != nil)
+	}
+	if c, err := pageRequest.Cookie("session"); err != nil || c.Value != "abc" {
+		t.Errorf("session cookie not sent: %v", err)
+	}
+	if user, pass, ok := pageRequest.BasicAuth(); !ok || user != "user" || pass != "secret" {
+		t.Error("basic auth not sent to the start host")
+	}
+	if got := pageRequest.Header.Get("User-Agent"); got != "test-agent/3" {
+		t.Errorf("User-Agent %q", got)
+	}
+	if pageRequest.Header.Get("X-Test") != "1" || pageRequest.Header.Get("Accept-Language") != "ru" {
+		t.Errorf("custom headers not sent: %v", pageRequest.Header)
+	}
+	// Учётные данные и кука хоста не уходят на другой хост
+	if _, _, ok := otherRequest.BasicAuth(); ok {
+		t.Error("basic auth sent to another host")
+	}
+	if _, err := otherRequest.Cookie("session"); err == nil {
+		t.Error("host cookie sent to another host")
+	}
+}
@@ -0,0 +1,763 @@
+// Package mirror зеркалирует сайт в духе wget -m: рекурсивно скачивает
+// страницы и их ресурсы, переписывает ссылки для просмотра без сети,
+// умеет возобновлять обход, писать WARC и проверять ссылки без сохранения.
+//
+// Настройки задаются через Options, обход запускает Crawler.Run:
+//
+//	crawler, err := mirror.New(mirror.Options{
+//		URL:      "https://example.com/",
+//		MaxDepth: 2,
+//		Storage:  mirror.NewDirStorage("./example"),
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	err = crawler.Run(ctx)
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent значение User-Agent по умолчанию и имя программы в WARC
+const DefaultUserAgent = "Go-Wget/1.0"
+
+const (
+	defaultWorkers   = 5
+	defaultTimeout   = 30 * time.Second
+	defaultTries     = 3
+	defaultWaitRetry = 10 * time.Second
+
+	// maxFrameDepth предел вложенности фреймов в режиме PageRequisites
+	maxFrameDepth = 5
+)
+
+// Options настройки зеркалирования. Нулевые значения дают настройки по умолчанию.
+type Options struct {
+	URL      string // стартовый адрес
+	MaxDepth int    // глубина рекурсии, 0 — только стартовая страница
+	Workers  int    // число параллельных загрузчиков, по умолчанию 5
+
+	Storage   Storage // куда сохраняются файлы, по умолчанию в память
+	StateFile string  // файл состояния для условных запросов и возобновления, "" — без сохранения
+	TempDir   string  // каталог временных файлов, "" — os.TempDir()
+
+	Scope        *ScopePolicy // область обхода, по умолчанию стартовый хост
+	ConvertLinks bool         // переписать ссылки для просмотра без сети
+	Sitemap      bool         // добавить страницы из sitemap.xml и robots.txt
+
+	// PageRequisites скачивает только стартовую страницу и всё, что нужно
+	// для её отображения: картинки, стили (рекурсивно), скрипты, шрифты
+	// и фреймы, в том числе с других хостов. По <a> и <link rel="next">
+	// обход не идёт, MaxDepth не учитывается; фреймы разбираются
+	// до вложенности 5.
+	PageRequisites bool
+
+	Timeout      time.Duration // таймаут запроса, по умолчанию 30 секунд
+	UserAgent    string        // по умолчанию DefaultUserAgent
+	Headers      http.Header   // дополнительные заголовки запросов
+	AuthUser     string        // Basic-авторизация на стартовом хосте
+	AuthPassword string
+	Cookies      *CookieJar // по умолчанию пустое хранилище
+
+	MaxFileSize int64         // 0 — без ограничения
+	Quota       int64         // общий лимит скачанных байт, загрузка обрывается на нём; 0 — без ограничения
+	Tries       int           // число попыток для временных ошибок, по умолчанию 3
+	WaitRetry   time.Duration // максимальная пауза между попытками, в том числе по Retry-After; по умолчанию 10 секунд
+
+	MaxPerHost int           // одновременных загрузок с одного хоста, 0 — без ограничения
+	Wait       time.Duration // пауза между запросами к одному хосту
+	RandomWait bool          // пауза случайна в пределах 0.5–1.5 × Wait
+	LimitRate  int64         // общий лимит скорости в байтах/с, 0 — без ограничения
+
+	WARC   *WARCWriter // архив обменов; создаёт и закрывает вызывающий код
+	Spider bool        // только проверять ссылки, ничего не сохраняя
+
+	Progress io.Writer // строка прогресса и сообщения, nil — без вывода
+
+	// Хуки вызываются из воркеров конкурентно
+	OnFetched        func(Fetched) // ресурс успешно получен
+	OnLinkDiscovered func(Link)    // на странице найдена ссылка
+}
+
+// Fetched сведения о полученном ресурсе для хука OnFetched
+type Fetched struct {
+	URL         string // адрес из очереди
+	FinalURL    string // адрес после редиректов
+	StatusCode  int
+	ContentType string
+	Size        int64
+	LocalPath   string // путь в хранилище, пустой в режиме Spider
+}
+
+// Link ссылка, найденная на странице, для хука OnLinkDiscovered
+type Link struct {
+	From     string // страница
+	To       string // абсолютный адрес ссылки
+	Resource bool   // ресурс страницы (img, script, link...), а не переход по <a>
+	InScope  bool   // ссылка попадает в область обхода и будет скачана
+}
+
+// Crawler выполняет один обход сайта
+type Crawler struct {
+	opts Options
+	ctx  context.Context
+
+	scope     *ScopePolicy
+	cookies   *CookieJar
+	client    *http.Client
+	storage   Storage
+	state     *CrawlState
+	paths     *PathMapper
+	scheduler *Scheduler   // очередь задач с лимитами по хостам
+	limiter   *RateLimiter // общий лимит скорости, nil — без ограничения
+	progress  *Progress
+	failures  *FailureReport
+	spider    *SpiderReport // только в режиме Spider
+
+	visited sync.Map
+	workers sync.WaitGroup
+
+	// saveMu упорядочивает сохранение файлов и переносы файлов, уступивших
+	// имя: перенос не должен обогнать сохранение переносимого файла
+	saveMu sync.Mutex
+}
+
+// New проверяет настройки и готовит обход. Состояние прошлого запуска
+// читается из StateFile, если он задан.
+func New(opts Options) (*Crawler, error) {
+	if opts.URL == "" {
+		return nil, errors.New("не указан URL")
+	}
+	startURL, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка парсинга URL: %w", err)
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.Tries <= 0 {
+		opts.Tries = defaultTries
+	}
+	if opts.WaitRetry <= 0 {
+		opts.WaitRetry = defaultWaitRetry
+	}
+	if opts.Storage == nil {
+		opts.Storage = NewMemoryStorage()
+	}
+	if opts.Progress == nil {
+		opts.Progress = io.Discard
+	}
+
+	c := &Crawler{
+		opts:      opts,
+		ctx:       context.Background(),
+		scope:     opts.Scope,
+		cookies:   opts.Cookies,
+		storage:   opts.Storage,
+		paths:     NewPathMapper(),
+		scheduler: NewScheduler(opts.MaxPerHost, opts.Wait, opts.RandomWait),
+		limiter:   NewRateLimiter(opts.LimitRate),
+		progress:  NewProgress(opts.Progress),
+		failures:  &FailureReport{},
+	}
+	if c.scope == nil {
+		if c.scope, err = NewScopePolicy(startURL, false, false, "", "", "", "", "", ""); err != nil {
+			return nil, err
+		}
+	}
+	if c.cookies == nil {
+		if c.cookies, err = NewCookieJar(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Spider {
+		c.spider = NewSpiderReport()
+	}
+
+	// Загружаем состояние прошлых запусков
+	if opts.StateFile == "" {
+		c.state = NewCrawlState("")
+	} else if c.state, err = LoadState(opts.StateFile); err != nil {
+		return nil, fmt.Errorf("ошибка чтения состояния: %w", err)
+	}
+	if dir, ok := c.storage.(*DirStorage); ok {
+		c.state.relocate(dir.Root())
+	}
+
+	// Восстанавливаем назначенные ранее локальные пути и редиректы
+	for _, res := range c.state.Resources {
+		if res.RedirectTo != "" {
+			c.paths.Alias(res.URL, res.RedirectTo)
+			continue
+		}
+		c.paths.Register(res.URL, res.LocalPath)
+	}
+
+	// WARC пишется под распаковкой, со сжатым телом из сети;
+	// сохранение и разбор получают распакованный ответ
+	var transport http.RoundTripper = http.DefaultTransport
+	if opts.WARC != nil {
+		transport = &warcTransport{base: transport, warc: opts.WARC, tempDir: opts.TempDir, logf: c.progress.Logf}
+	}
+	c.client = &http.Client{
+		Timeout:       opts.Timeout,
+		Jar:           c.cookies,
+		Transport:     newDecodingTransport(transport),
+		CheckRedirect: c.checkRedirect(),
+	}
+	return c, nil
+}
+
+// Run выполняет обход до конца или до отмены ctx. При отмене фронтир
+// сохраняется в StateFile, и следующий запуск с тем же файлом продолжит
+// обход; Run в этом случае возвращает ошибку контекста.
+func (c *Crawler) Run(ctx context.Context) error {
+	c.ctx = ctx
+
+	stop := make(chan struct{})
+	go c.persistState(stop)
+	go c.progress.Run(stop)
+
+	if c.state.Interrupted() {
+		// Возобновляем прерванный обход с сохранённого фронтира
+		pending := c.state.PendingTasks()
+		c.progress.Logf("Возобновление прерванного обхода: %d задач в очереди", len(pending))
+		for _, u := range c.state.DoneURLs() {
+			c.visited.Store(u, true)
+		}
+		for _, task := range pending {
+			c.enqueue(task)
+		}
+	} else {
+		// Добавляем начальную задачу
+		if c.spider != nil {
+			c.spider.AddEdge("", c.opts.URL)
+		}
+		c.enqueue(DownloadTask{
+			URL:   c.opts.URL,
+			Depth: 0,
+			Type:  "html",
+		})
+		if c.opts.Sitemap {
+			c.seedFromSitemaps(c.scope.StartURL)
+		}
+	}
+
+	// Отмена останавливает выдачу задач, воркеры доделывают текущие
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.scheduler.Close()
+		case <-stop:
+		}
+	}()
+
+	// Воркеры завершаются, когда очередь пуста и задач в работе нет
+	for i := 0; i < c.opts.Workers; i++ {
+		c.workers.Add(1)
+		go c.worker()
+	}
+	c.workers.Wait()
+	close(stop)
+	c.progress.Finish()
+
+	if err := ctx.Err(); err != nil {
+		if saveErr := c.state.Save(); saveErr != nil {
+			return fmt.Errorf("ошибка сохранения состояния: %w", saveErr)
+		}
+		return err
+	}
+
+	if c.opts.ConvertLinks && !c.opts.Spider {
+		c.progress.Logf("Преобразование ссылок...")
+		c.convertLinks()
+	}
+
+	c.state.Finish()
+	if err := c.state.Save(); err != nil {
+		return fmt.Errorf("ошибка сохранения состояния: %w", err)
+	}
+	return nil
+}
+
+// Failures возвращает отчёт о ресурсах, которые не удалось скачать
+func (c *Crawler) Failures() *FailureReport {
+	return c.failures
+}
+
+// SpiderReport возвращает отчёт проверки ссылок; nil, если режим Spider выключен
+func (c *Crawler) SpiderReport() *SpiderReport {
+	return c.spider
+}
+
+// DownloadTask представляет задачу на скачивание
+type DownloadTask struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+	Type  string `json:"type"` // html — переход, frame — фрейм, resource — прочие ресурсы
+}
+
+// fetchResult результат скачивания ресурса
+type fetchResult struct {
+	Content      []byte // только для HTML и CSS, остальное лежит в TempPath
+	TempPath     string
+	Size         int64
+	Hash         string
+	ContentType  string
+	ETag         string
+	LastModified string
+	NotModified  bool     // сервер ответил 304, локальная копия актуальна
+	FinalURL     string   // адрес после всех редиректов
+	Redirects    []string // промежуточные и конечный адреса редиректов
+
+	WARCRecordID string        // запись response в WARC, если архив ведётся
+	FetchTime    time.Duration // время от запроса до конца тела
+}
+
+var (
+	errFileTooLarge  = errors.New("файл превышает допустимый размер")
+	errQuotaExceeded = errors.New("исчерпана квота загрузки")
+)
+
+// persistState сохраняет состояние каждые несколько секунд, чтобы
+// аварийно прерванный обход можно было продолжить
+func (c *Crawler) persistState(stop <-chan struct{}) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := c.state.Save(); err != nil {
+				c.progress.Logf("Ошибка сохранения состояния: %v", err)
+			}
+		}
+	}
+}
+
+// enqueue добавляет задачу в очередь, если URL ещё не встречался
+func (c *Crawler) enqueue(task DownloadTask) {
+	if _, visited := c.visited.LoadOrStore(task.URL, true); visited {
+		return
+	}
+	c.progress.Queued(1)
+	c.state.AddPending(task)
+	c.scheduler.Push(task)
+}
+
+// worker обрабатывает задачи скачивания
+func (c *Crawler) worker() {
+	defer c.workers.Done()
+
+	for {
+		task, ok := c.scheduler.Next()
+		if !ok {
+			return
+		}
+		if c.opts.Spider {
+			c.spiderTask(task)
+		} else {
+			c.processTask(task)
+		}
+		c.scheduler.Done(task)
+		// Прерванная отменой задача остаётся во фронтире
+		if c.ctx.Err() == nil {
+			c.state.MarkDone(task.URL)
+		}
+		c.progress.Queued(-1)
+	}
+}
+
+// processTask скачивает один ресурс, сохраняет его и ставит в очередь найденные ссылки
+func (c *Crawler) processTask(task DownloadTask) {
+	prev := c.state.Get(task.URL)
+	// Для URL с редиректом условный запрос строится по конечному ресурсу:
+	// клиент перенесёт заголовки в запрос по новому адресу
+	if prev != nil && prev.RedirectTo != "" {
+		prev = c.state.Get(prev.RedirectTo)
+	}
+
+	// Скачиваем ресурс
+	res, err := c.fetchWithRetry(task, prev)
+	if err != nil {
+		if c.ctx.Err() != nil {
+			return
+		}
+		c.progress.Failed()
+		c.progress.Logf("Ошибка скачивания %s: %v", task.URL, err)
+		return
+	}
+
+	// Ресурс хранится под конечным адресом, исходный становится псевдонимом
+	finalURL := task.URL
+	if res.FinalURL != "" && res.FinalURL != task.URL {
+		finalURL = res.FinalURL
+		c.paths.Alias(task.URL, finalURL)
+		c.state.Update(&ResourceState{URL: task.URL, RedirectTo: finalURL, Redirects: res.Redirects})
+
+		// Конечный адрес уже скачан или скачивается другой задачей
+		if _, visited := c.visited.LoadOrStore(finalURL, true); visited {
+			if res.TempPath != "" {
+				os.Remove(res.TempPath)
+			}
+			return
+		}
+	}
+
+	// Локальная копия актуальна — повторно обходим ссылки, найденные в прошлый раз
+	if res.NotModified {
+		c.fetched(Fetched{
+			URL:         task.URL,
+			FinalURL:    finalURL,
+			StatusCode:  http.StatusNotModified,
+			ContentType: prev.ContentType,
+			LocalPath:   prev.LocalPath,
+		})
+		if c.followsChildren(task, prev.ContentType) {
+			c.enqueueChildren(finalURL, task, prev.Links, prev.Resources, prev.Frames)
+		}
+		return
+	}
+
+	current := &ResourceState{
+		URL:          finalURL,
+		ETag:         res.ETag,
+		LastModified: res.LastModified,
+		Hash:         res.Hash,
+		ContentType:  res.ContentType,
+	}
+
+	// Содержимое совпало с сохранённым — файл можно не перезаписывать
+	if prev != nil && prev.Hash == res.Hash && c.storage.Exists(prev.LocalPath) {
+		os.Remove(res.TempPath)
+		current.LocalPath = prev.LocalPath
+	} else {
+		localPath, err := c.saveResource(finalURL, res)
+		if err != nil {
+			os.Remove(res.TempPath)
+			c.progress.Failed()
+			c.progress.Logf("Ошибка сохранения %s: %v", finalURL, err)
+			return
+		}
+		current.LocalPath = localPath
+		c.progress.FileDone()
+	}
+
+	// Разбираем HTML до максимальной глубины и таблицы стилей
+	if c.followsChildren(task, res.ContentType) {
+		// Относительные ссылки разрешаются от конечного адреса
+		baseURL, _ := url.Parse(finalURL)
+		if isCSSContent(res.ContentType) {
+			current.Resources = parseCSS(res.Content, baseURL)
+		} else {
+			// Парсер работает с UTF-8, на диске остаются исходные байты
+			current.Links, current.Resources, current.Frames = parseHTMLSimple(toUTF8(res.Content, res.ContentType), baseURL)
+		}
+
+		// Ссылки переписываются после обхода в convertLinks,
+		// когда известно, какие ресурсы скачаны
+		c.enqueueChildren(finalURL, task, current.Links, current.Resources, current.Frames)
+	}
+
+	if c.opts.WARC != nil && res.WARCRecordID != "" {
+		c.writeWARCMetadata(res, current)
+	}
+
+	c.commit(current)
+	c.fetched(Fetched{
+		URL:         task.URL,
+		FinalURL:    finalURL,
+		StatusCode:  http.StatusOK,
+		ContentType: res.ContentType,
+		Size:        res.Size,
+		LocalPath:   current.LocalPath,
+	})
+}
+
+// followsChildren сообщает, нужно ли разбирать ресурс и ставить в очередь
+// найденное в нём. Таблицы стилей ссылаются только на ресурсы и разбираются
+// всегда, HTML — до MaxDepth. В режиме PageRequisites разбираются только
+// стартовая страница и фреймы до maxFrameDepth: HTML, пришедший как
+// картинка или скрипт, и переходы не разбираются.
+func (c *Crawler) followsChildren(task DownloadTask, contentType string) bool {
+	if isCSSContent(contentType) {
+		return true
+	}
+	if !isHTMLContent(contentType) {
+		return false
+	}
+	if c.opts.PageRequisites {
+		return task.Depth == 0 || task.Type == "frame" && task.Depth <= maxFrameDepth
+	}
+	return task.Depth < c.opts.MaxDepth
+}
+
+// fetched вызывает хук OnFetched, если он задан
+func (c *Crawler) fetched(f Fetched) {
+	if c.opts.OnFetched != nil {
+		c.opts.OnFetched(f)
+	}
+}
+
+// writeWARCMetadata пишет запись metadata с временем загрузки и исходящими ссылками
+func (c *Crawler) writeWARCMetadata(res *fetchResult, current *ResourceState) {
+	fields := []warcField{{"fetchTimeMs", strconv.FormatInt(res.FetchTime.Milliseconds(), 10)}}
+	for _, link := range current.Links {
+		fields = append(fields, warcField{"outlink", link + " L a/@href"})
+	}
+	for _, res := range current.Resources {
+		fields = append(fields, warcField{"outlink", res + " E"})
+	}
+	if err := c.opts.WARC.writeMetadata(current.URL, res.WARCRecordID, fields); err != nil {
+		c.progress.Logf("Ошибка записи WARC для %s: %v", current.URL, err)
+	}
+}
+
+// enqueueChildren ставит в очередь страницы и ресурсы, найденные на странице
+// from, если их пропускает политика области обхода; frames — фреймы среди resources
+func (c *Crawler) enqueueChildren(from string, task DownloadTask, links, resources, frames []string) {
+	for _, link := range links {
+		if !c.discovered(from, link, false) {
+			continue
+		}
+		c.enqueue(DownloadTask{
+			URL:   link,
+			Depth: task.Depth + 1,
+			Type:  "html",
+		})
+	}
+
+	// Добавляем ресурсы (CSS, JS, изображения) и фреймы
+	isFrame := make(map[string]bool, len(frames))
+	for _, f := range frames {
+		isFrame[f] = true
+	}
+	for _, res := range resources {
+		if !c.discovered(from, res, true) {
+			continue
+		}
+		taskType := "resource"
+		if isFrame[res] {
+			taskType = "frame"
+		}
+		c.enqueue(DownloadTask{
+			URL:   res,
+			Depth: task.Depth + 1,
+			Type:  taskType,
+		})
+	}
+}
+
+// discovered проверяет найденную ссылку по области обхода, сообщает о ней
+// хуку OnLinkDiscovered и отчёту Spider. Возвращает true, если ссылку нужно скачать.
+func (c *Crawler) discovered(from, to string, resource bool) bool {
+	inScope := c.shouldDownload(to, !resource)
+	if c.opts.OnLinkDiscovered != nil {
+		c.opts.OnLinkDiscovered(Link{From: from, To: to, Resource: resource, InScope: inScope})
+	}
+	if inScope && c.spider != nil {
+		c.spider.AddEdge(from, to)
+	}
+	return inScope
+}
+
+// downloadResource скачивает ресурс задачи во временный файл.
+// Если ресурс уже скачивался, отправляется условный запрос по ETag и Last-Modified.
+// В памяти сохраняется только HTML и CSS, которые нужно разбирать.
+func (c *Crawler) downloadResource(task DownloadTask, prev *ResourceState) (*fetchResult, error) {
+	if c.opts.Quota > 0 && c.progress.Bytes() >= c.opts.Quota {
+		return nil, errQuotaExceeded
+	}
+
+	req, err := c.newRequest("GET", task.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Редиректы ресурсов проверяются как ресурсы, а не как переходы
+	req = withRedirectPage(req, task.Type == "html")
+
+	// Условный запрос имеет смысл, только если локальный файл на месте
+	if prev != nil && c.storage.Exists(prev.LocalPath) {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
+	var chain []string
+	req = withRedirectChain(req, &chain)
+	// Все ответы, включая ошибки и редиректы, архивирует warcTransport
+	var recordID string
+	req = withWARCRecord(req, &recordID)
+
+	started := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// После редиректов запрос ответа — последний в цепочке
+	finalReq := resp.Request
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotModified && prev != nil {
+			return &fetchResult{NotModified: true, FinalURL: finalReq.URL.String(), Redirects: chain}, nil
+		}
+		return nil, newHTTPError(resp)
+	}
+
+	if c.opts.MaxFileSize > 0 && resp.ContentLength > c.opts.MaxFileSize {
+		return nil, fmt.Errorf("%w: %d байт", errFileTooLarge, resp.ContentLength)
+	}
+
+	result := &fetchResult{
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FinalURL:     finalReq.URL.String(),
+		Redirects:    chain,
+	}
+	if err := c.streamToTemp(resp.Body, result); err != nil {
+		return nil, err
+	}
+	result.FetchTime = time.Since(started)
+
+	// Запись response появляется при закрытии тела
+	resp.Body.Close()
+	result.WARCRecordID = recordID
+	return result, nil
+}
+
+// streamToTemp пишет тело ответа во временный файл, попутно считая хеш,
+// размер и, для HTML и CSS, копию в памяти
+func (c *Crawler) streamToTemp(body io.Reader, result *fetchResult) error {
+	tmp, err := os.CreateTemp(c.opts.TempDir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	hash := sha256.New()
+	writers := []io.Writer{tmp, hash, progressWriter{progress: c.progress, quota: c.opts.Quota}}
+	var buf bytes.Buffer
+	if needsParsing(result.ContentType) {
+		writers = append(writers, &buf)
+	}
+
+	// Читаем на байт больше лимита, чтобы заметить превышение
+	reader := c.limiter.Reader(c.ctx, body)
+	if c.opts.MaxFileSize > 0 {
+		reader = io.LimitReader(reader, c.opts.MaxFileSize+1)
+	}
+
+	size, err := io.Copy(io.MultiWriter(writers...), reader)
+	if err == nil && c.opts.MaxFileSize > 0 && size > c.opts.MaxFileSize {
+		err = fmt.Errorf("%w: больше %d байт", errFileTooLarge, c.opts.MaxFileSize)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	result.TempPath = tmp.Name()
+	result.Size = size
+	result.Hash = fmt.Sprintf("%x", hash.Sum(nil)[:16])
+	result.Content = buf.Bytes()
+	return nil
+}
+
+// progressWriter учитывает записанные байты в индикаторе прогресса.
+// С quota > 0 загрузка обрывается, как только общий объём превысит квоту:
+// проверки перед запросом мало, один большой файл превысил бы её на любую величину.
+type progressWriter struct {
+	progress *Progress
+	quota    int64
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.progress.AddBytes(int64(len(p)))
+	if w.quota > 0 && w.progress.Bytes() > w.quota {
+		return 0, errQuotaExceeded
+	}
+	return len(p), nil
+}
+
+// needsParsing сообщает, нужно ли держать содержимое в памяти для разбора
+func needsParsing(contentType string) bool {
+	return isHTMLContent(contentType) || isCSSContent(contentType)
+}
+
+// saveResource переносит скачанный временный файл в хранилище,
+// путь назначает c.paths. Файлы, уступившие имя новому, переносятся до него.
+func (c *Crawler) saveResource(urlStr string, res *fetchResult) (string, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return "", err
+	}
+
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	name, moves := c.paths.Assign(parsedURL, res.ContentType)
+	for _, mv := range moves {
+		if err := renameFile(c.storage, mv.From, mv.To); err != nil {
+			return "", fmt.Errorf("перенос %s: %w", mv.From, err)
+		}
+		c.state.setLocalPath(mv.URL, mv.To)
+	}
+	if err := putFile(c.storage, name, res.TempPath); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// commit записывает сведения о ресурсе в состояние. Путь берётся из c.paths
+// под saveMu: файл мог быть перенесён после сохранения.
+func (c *Crawler) commit(current *ResourceState) {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	if name, ok := c.paths.Lookup(current.URL); ok && current.LocalPath != "" {
+		current.LocalPath = name
+	}
+	c.state.Update(current)
+}
+
+// shouldDownload проверяет, нужно ли скачивать ссылку: page — страница из <a>,
+// иначе ресурс страницы. В режиме PageRequisites страницы не скачиваются,
+// а ресурсы берутся с любых хостов.
+func (c *Crawler) shouldDownload(urlStr string, page bool) bool {
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	if c.opts.PageRequisites {
+		return !page && c.scope.AllowRequisite(parsed)
+	}
+	return c.scope.Allow(parsed, page)
+}
@@ -0,0 +1,221 @@
+package mirror
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPage ответ тестового сайта; пустой Type определяется по расширению пути
+type testPage struct {
+	Body   string
+	Type   string
+	Status int
+	Header http.Header
+}
+
+// newTestSite запускает сайт из страниц pages (путь → ответ)
+// и возвращает сервер и журнал запросов
+func newTestSite(t *testing.T, pages map[string]testPage) (*httptest.Server, *requestLog) {
+	t.Helper()
+	log := &requestLog{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		page, ok := pages[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		for k, v := range page.Header {
+			w.Header()[k] = v
+		}
+		contentType := page.Type
+		if contentType == "" {
+			contentType = typeByPath(r.URL.Path)
+		}
+		w.Header().Set("Content-Type", contentType)
+		if page.Status != 0 {
+			w.WriteHeader(page.Status)
+		}
+		w.Write([]byte(page.Body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, log
+}
+
+// typeByPath Content-Type тестовой страницы по расширению
+func typeByPath(p string) string {
+	switch path.Ext(p) {
+	case ".css":
+		return "text/css"
+	case ".png":
+		return "image/png"
+	case ".js":
+		return "text/javascript"
+	case ".woff":
+		return "font/woff"
+	}
+	return "text/html; charset=utf-8"
+}
+
+// requestLog запросы, полученные тестовым сервером
+type requestLog struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (l *requestLog) add(r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, r.Clone(context.Background()))
+}
+
+// paths возвращает запрошенные пути с query по алфавиту
+func (l *requestLog) paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var paths []string
+	for _, r := range l.requests {
+		paths = append(paths, r.URL.RequestURI())
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// find возвращает последний запрос по пути или nil
+func (l *requestLog) find(uri string) *http.Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.requests) - 1; i >= 0; i-- {
+		if l.requests[i].URL.RequestURI() == uri {
+			return l.requests[i]
+		}
+	}
+	return nil
+}
+
+// hostPrefix каталог хоста сервера в хранилище
+func hostPrefix(srv *httptest.Server) string {
+	u, _ := url.Parse(srv.URL)
+	return hostDir(u)
+}
+
+// runCrawl выполняет обход с опциями opts. По умолчанию файлы сохраняются
+// в MemoryStorage, повторов нет, а в выводе прогресса ничего не печатается.
+func runCrawl(t *testing.T, opts Options) (*Crawler, *MemoryStorage) {
+	t.Helper()
+	storage, _ := opts.Storage.(*MemoryStorage)
+	if opts.Storage == nil {
+		storage = NewMemoryStorage()
+		opts.Storage = storage
+	}
+	if opts.Tries == 0 {
+		opts.Tries = 1
+	}
+
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return c, storage
+}
+
+// fileString возвращает содержимое файла из хранилища или проваливает тест
+func fileString(t *testing.T, s *MemoryStorage, name string) string {
+	t.Helper()
+	data, ok := s.File(name)
+	if !ok {
+		t.Fatalf("file %s not saved; have %v", name, s.Names())
+	}
+	return string(data)
+}
+
+func TestRunMirrorsSite(t *testing.T) {
+	srv, log := newTestSite(t, map[string]testPage{
+		"/":          {Body: `<a href="a.html">a</a><img src="img.png"><link rel="stylesheet" href="style.css">`},
+		"/a.html":    {Body: `<a href="b.html">b</a>`},
+		"/b.html":    {Body: `b`},
+		"/img.png":   {Body: "PNG"},
+		"/style.css": {Body: `body { background: url("bg.png") }`},
+		"/bg.png":    {Body: "BG"},
+	})
+
+	var mu sync.Mutex
+	var fetched []string
+	c, storage := runCrawl(t, Options{
+		URL:      srv.URL + "/",
+		MaxDepth: 1,
+		OnFetched: func(f Fetched) {
+			mu.Lock()
+			defer mu.Unlock()
+			fetched = append(fetched, f.URL)
+		},
+	})
+
+	host := hostPrefix(srv)
+	want := []string{
+		host + "/a.html",
+		host + "/bg.png",
+		host + "/img.png",
+		host + "/index.html",
+		host + "/style.css",
+	}
+	if got := storage.Names(); !slices.Equal(got, want) {
+		t.Errorf("saved %v, want %v", got, want)
+	}
+	// b.html глубже MaxDepth, а ресурсы таблицы стилей берутся всегда
+	if got := log.paths(); slices.Contains(got, "/b.html") {
+		t.Errorf("requested %v, b.html is past MaxDepth", got)
+	}
+	if len(fetched) != len(want) {
+		t.Errorf("OnFetched called for %v, want %d resources", fetched, len(want))
+	}
+	if n := c.Failures().Len(); n != 0 {
+		t.Errorf("%d failures, want none", n)
+	}
+	if got := fileString(t, storage, host+"/img.png"); got != "PNG" {
+		t.Errorf("img.png = %q", got)
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/": {Body: `<a href="missing.html">x</a><img src="gone.png">`},
+	})
+
+	var mu sync.Mutex
+	var links []Link
+	c, _ := runCrawl(t, Options{
+		URL:      srv.URL + "/",
+		MaxDepth: 1,
+		OnLinkDiscovered: func(l Link) {
+			mu.Lock()
+			defer mu.Unlock()
+			links = append(links, l)
+		},
+	})
+
+	if n := c.Failures().Len(); n != 2 {
+		t.Errorf("%d failures, want 2", n)
+	}
+	slices.SortFunc(links, func(a, b Link) int { return strings.Compare(a.To, b.To) })
+	want := []Link{
+		{From: srv.URL + "/", To: srv.URL + "/gone.png", Resource: true, InScope: true},
+		{From: srv.URL + "/", To: srv.URL + "/missing.html", InScope: true},
+	}
+	if !slices.Equal(links, want) {
+		t.Errorf("links %+v, want %+v", links, want)
+	}
+}
@@ -0,0 +1,82 @@
+package mirror
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// cssURLPattern url(...) в кавычках или без них
+	cssURLPattern = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^'"()\s]+))\s*\)`)
+	// cssImportPattern @import со строкой; @import url(...) покрывает cssURLPattern
+	cssImportPattern = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+	// styleBlockPattern содержимое элементов <style>
+	styleBlockPattern = regexp.MustCompile(`(?is)(<style\b[^>]*>)(.*?)(</style\s*>)`)
+	// styleAttrPattern атрибут style="..." или style='...'
+	styleAttrPattern = regexp.MustCompile(`(?i)\sstyle\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// isCSSContent проверяет, является ли контент таблицей стилей
+func isCSSContent(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "text/css")
+}
+
+// parseCSS извлекает из таблицы стилей адреса картинок, шрифтов
+// и импортированных таблиц
+func parseCSS(content []byte, baseURL *url.URL) []string {
+	return extractCSSLinks(string(content), baseURL)
+}
+
+// extractCSSLinks возвращает абсолютные адреса из url() и @import
+func extractCSSLinks(css string, baseURL *url.URL) []string {
+	var results []string
+	replaceCSSLinks(css, func(href string) string {
+		if absoluteURL := resolveURL(href, baseURL); absoluteURL != "" {
+			results = append(results, absoluteURL)
+		}
+		return href
+	})
+	return removeDuplicates(results)
+}
+
+// replaceCSSLinks заменяет адреса в url() и @import с помощью convert,
+// сохраняя кавычки и остальной текст
+func replaceCSSLinks(css string, convert func(string) string) string {
+	css = replaceGroups(cssURLPattern, css, convert)
+	return replaceGroups(cssImportPattern, css, convert)
+}
+
+// replaceGroups заменяет через convert первую сработавшую группу каждого
+// совпадения re; невалидные ссылки (data:, javascript: и т.п.) не трогаются
+func replaceGroups(re *regexp.Regexp, s string, convert func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		for g := 1; 2*g < len(m); g++ {
+			start, end := m[2*g], m[2*g+1]
+			if start < 0 {
+				continue
+			}
+			if isValidLink(s[start:end]) {
+				b.WriteString(s[last:start])
+				b.WriteString(convert(s[start:end]))
+				last = end
+			}
+			break
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// rewriteInlineStyles применяет fn к CSS внутри элементов <style>
+// и атрибутов style
+func rewriteInlineStyles(content string, fn func(string) string) string {
+	content = styleBlockPattern.ReplaceAllStringFunc(content, func(block string) string {
+		m := styleBlockPattern.FindStringSubmatch(block)
+		return m[1] + fn(m[2]) + m[3]
+	})
+	return replaceGroups(styleAttrPattern, content, fn)
+}
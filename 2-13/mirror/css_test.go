@@ -0,0 +1,57 @@
+package mirror
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestExtractCSSLinks(t *testing.T) {
+	css := `@import "base.css";
+@import url(print.css) print;
+body { background: url( 'img/bg.png' ) }
+.a { background-image: URL("../up.png"), url(data:image/png;base64,AAAA) }
+@font-face { src: url(font.woff2?v=2#iefix) format("woff2") }
+.b { background: url(img/bg.png) }`
+	base, _ := url.Parse("http://example.com/css/site.css")
+	want := []string{
+		"http://example.com/css/print.css",
+		"http://example.com/css/img/bg.png",
+		"http://example.com/up.png",
+		"http://example.com/css/font.woff2?v=2#iefix",
+		"http://example.com/css/base.css",
+	}
+	if got := extractCSSLinks(css, base); !slices.Equal(got, want) {
+		t.Errorf("links %v, want %v", got, want)
+	}
+}
+
+func TestReplaceCSSLinks(t *testing.T) {
+	tests := map[string]string{
+		`@import "a.css";`:                       `@import "[a.css]";`,
+		`@import 'a.css' screen;`:                `@import '[a.css]' screen;`,
+		`x { b: url("a.png") url('b.png') }`:     `x { b: url("[a.png]") url('[b.png]') }`,
+		`x { b: url( c.png ) }`:                  `x { b: url( [c.png] ) }`,
+		`x { b: url(data:image/gif;base64,R0) }`: `x { b: url(data:image/gif;base64,R0) }`,
+		`x { b: url(#svg-filter) }`:              `x { b: url(#svg-filter) }`,
+	}
+	for css, want := range tests {
+		got := replaceCSSLinks(css, func(href string) string { return "[" + href + "]" })
+		if got != want {
+			t.Errorf("replaceCSSLinks(%q) = %q, want %q", css, got, want)
+		}
+	}
+}
+
+func TestRewriteInlineStyles(t *testing.T) {
+	html := `<style media="screen">a { b: url(x.png) }</style><p style='c: url("y.png")'>` +
+		`<div data-style="url(no.png)"><STYLE>d { e: url(z.png) }</STYLE >`
+	want := `<style media="screen">a { b: url([x.png]) }</style><p style='c: url("[y.png]")'>` +
+		`<div data-style="url(no.png)"><STYLE>d { e: url([z.png]) }</STYLE >`
+	got := rewriteInlineStyles(html, func(css string) string {
+		return replaceCSSLinks(css, func(href string) string { return "[" + href + "]" })
+	})
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package mirror
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// acceptEncoding сжатия, которые умеет распаковывать decodingTransport
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// decodingTransport запрашивает сжатые ответы и распаковывает их сам.
+// Стандартный транспорт делает это только для gzip и только если
+// Accept-Encoding не задан явно. Стоит над warcTransport: архив получает
+// ответ как есть, а распакованный ответ — копия с исправленными заголовками.
+type decodingTransport struct {
+	base http.RoundTripper
+}
+
+// newDecodingTransport оборачивает транспорт распаковкой ответов
+func newDecodingTransport(base http.RoundTripper) *decodingTransport {
+	return &decodingTransport{base: base}
+}
+
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Заголовок из -header имеет приоритет, распаковываем всё, что умеем
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || req.Method == http.MethodHead {
+		return resp, err
+	}
+
+	codings := contentCodings(resp.Header.Get("Content-Encoding"))
+	if len(codings) == 0 {
+		return resp, nil
+	}
+	for _, c := range codings {
+		if !supportedCoding(c) {
+			// Неизвестное сжатие: отдаём тело как есть, заголовок остаётся
+			return resp, nil
+		}
+	}
+
+	body := &decodedBody{raw: resp.Body}
+	var r io.Reader = resp.Body
+	// Сжатия перечислены в порядке применения, снимаем с последнего
+	for i := len(codings) - 1; i >= 0; i-- {
+		r = &lazyDecoder{coding: codings[i], src: r, body: body}
+	}
+	body.Reader = r
+
+	// Исходный ответ и его заголовки не меняются: их видят слои ниже
+	decoded := *resp
+	decoded.Header = resp.Header.Clone()
+	decoded.Header.Del("Content-Encoding")
+	decoded.Header.Del("Content-Length")
+	decoded.Body = body
+	decoded.ContentLength = -1
+	decoded.Uncompressed = true
+	return &decoded, nil
+}
+
+// contentCodings разбирает Content-Encoding, пропуская identity
+func contentCodings(header string) []string {
+	var codings []string
+	for _, c := range strings.Split(header, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && c != "identity" {
+			codings = append(codings, c)
+		}
+	}
+	return codings
+}
+
+// supportedCoding сообщает, умеем ли мы распаковать сжатие
+func supportedCoding(coding string) bool {
+	switch coding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+// decodedBody распакованное тело ответа; Close освобождает декодеры
+// и закрывает исходное тело
+type decodedBody struct {
+	io.Reader
+	raw     io.ReadCloser
+	closers []func()
+	closed  bool
+}
+
+func (b *decodedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, c := range b.closers {
+		c()
+	}
+	return b.raw.Close()
+}
+
+// lazyDecoder создает декодер при первом чтении: у ответов без тела
+// (например, 304) заголовок сжатия может быть, а данных нет
+type lazyDecoder struct {
+	coding string
+	src    io.Reader
+	body   *decodedBody
+	r      io.Reader
+	err    error
+}
+
+func (d *lazyDecoder) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.open()
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+// open создает декодер для d.coding; пустое тело остаётся пустым
+func (d *lazyDecoder) open() (io.Reader, error) {
+	br := bufio.NewReader(d.src)
+	if _, err := br.Peek(1); err == io.EOF {
+		return br, nil
+	}
+	d.src = br
+
+	switch d.coding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(d.src)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		return newDeflateReader(d.src)
+	case "br":
+		return brotli.NewReader(d.src), nil
+	case "zstd":
+		zr, err := zstd.NewReader(d.src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		d.body.closers = append(d.body.closers, zr.Close)
+		return zr, nil
+	}
+	return nil, fmt.Errorf("неподдерживаемое сжатие %q", d.coding)
+}
+
+// newDeflateReader распаковывает deflate. По RFC 9110 это zlib-поток,
+// но часть серверов присылает «голый» deflate без заголовка.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(2)
+	if err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("deflate: %w", err)
+		}
+		return zr, nil
+	}
+	return flate.NewReader(br), nil
+}
+
+// htmlEncoding определяет кодировку HTML по BOM, Content-Type и <meta charset>
+func htmlEncoding(content []byte, contentType string) (encoding.Encoding, string) {
+	enc, name, _ := charset.DetermineEncoding(content, contentType)
+	return enc, name
+}
+
+// toUTF8 перекодирует HTML в UTF-8 для разбора ссылок.
+// На диск сохраняются исходные байты, перекодированный текст нужен только парсеру.
+func toUTF8(content []byte, contentType string) []byte {
+	enc, name := htmlEncoding(content, contentType)
+	if name == "utf-8" {
+		return content
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return content
+	}
+	return decoded
+}
+
+// fromUTF8 возвращает текст в исходную кодировку страницы после
+// переписывания ссылок. ok == false, если перекодировать без потерь нельзя.
+func fromUTF8(content []byte, enc encoding.Encoding, name string) ([]byte, bool) {
+	if name == "utf-8" {
+		return content, true
+	}
+	encoded, err := enc.NewEncoder().Bytes(content)
+	if err != nil {
+		return nil, false
+	}
+	return encoded, true
+}
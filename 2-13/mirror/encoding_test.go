@@ -0,0 +1,253 @@
+package mirror
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// compress сжимает data одним из поддерживаемых сжатий
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "zlib":
+		w = zlib.NewWriter(&b)
+	case "flate":
+		w, _ = flate.NewWriter(&b, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&b)
+	case "zstd":
+		w, _ = zstd.NewWriter(&b)
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// roundTripFunc транспорт из функции
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDecodingTransport(t *testing.T) {
+	plain := []byte(strings.Repeat("<p>decoded body</p>\n", 50))
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     []byte
+		header   string // Content-Encoding после распаковки
+	}{
+		{"gzip", "gzip", compress(t, "gzip", plain), plain, ""},
+		{"x-gzip", "x-gzip", compress(t, "gzip", plain), plain, ""},
+		{"deflate zlib", "deflate", compress(t, "zlib", plain), plain, ""},
+		{"deflate raw", "deflate", compress(t, "flate", plain), plain, ""},
+		{"br", "br", compress(t, "br", plain), plain, ""},
+		{"zstd", "zstd", compress(t, "zstd", plain), plain, ""},
+		{"stacked", "gzip, br", compress(t, "br", compress(t, "gzip", plain)), plain, ""},
+		{"identity", "identity", plain, plain, "identity"},
+		{"case and spaces", " GZIP ", compress(t, "gzip", plain), plain, ""},
+		{"unknown", "compress", []byte("LZW"), []byte("LZW"), "compress"},
+		{"unknown in chain", "gzip, compress", []byte("LZW"), []byte("LZW"), "gzip, compress"},
+	}
+	for _, tt := range tests {
+		var sent *http.Request
+		transport := newDecodingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			return &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Encoding": {tt.encoding}, "Content-Length": {"1"}},
+				Body:          io.NopCloser(bytes.NewReader(tt.body)),
+				ContentLength: int64(len(tt.body)),
+				Request:       req,
+			}, nil
+		}))
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("%s: read: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: body %q", tt.name, got)
+		}
+		if h := resp.Header.Get("Content-Encoding"); h != tt.header {
+			t.Errorf("%s: Content-Encoding %q, want %q", tt.name, h, tt.header)
+		}
+		if tt.header == "" && (resp.ContentLength != -1 || resp.Header.Get("Content-Length") != "") {
+			t.Errorf("%s: Content-Length kept after decoding", tt.name)
+		}
+		if sent.Header.Get("Accept-Encoding") != acceptEncoding {
+			t.Errorf("%s: Accept-Encoding %q", tt.name, sent.Header.Get("Accept-Encoding"))
+		}
+	}
+}
+
+func TestDecodingTransportPassThrough(t *testing.T) {
+	var sent *http.Request
+	transport := newDecodingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Header:     http.Header{"Content-Encoding": {"gzip"}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}))
+
+	// Accept-Encoding из -header не заменяется
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Header.Get("Accept-Encoding") != "gzip" {
+		t.Errorf("Accept-Encoding replaced: %q", sent.Header.Get("Accept-Encoding"))
+	}
+	// Ответ без тела со сжатием читается без ошибки
+	if data, err := io.ReadAll(resp.Body); err != nil || len(data) != 0 {
+		t.Errorf("empty 304 body: %q, %v", data, err)
+	}
+	resp.Body.Close()
+
+	// Ответ на HEAD не распаковывается
+	req, _ = http.NewRequest("HEAD", "http://example.com/", nil)
+	resp, _ = transport.RoundTrip(req)
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Error("HEAD response headers changed")
+	}
+}
+
+func TestDecodingTransportCorruptBody(t *testing.T) {
+	for _, coding := range []string{"gzip", "zstd"} {
+		transport := newDecodingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Encoding": {coding}},
+				Body:       io.NopCloser(strings.NewReader("not compressed")),
+				Request:    req,
+			}, nil
+		}))
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Errorf("%s: corrupt body read without error", coding)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestCharsetConversion(t *testing.T) {
+	encode := func(s string) []byte {
+		b, err := charmap.Windows1251.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	page := `<meta charset="windows-1251"><p>Привет</p><a href="страница.html">ссылка</a>`
+	encoded := encode(page)
+
+	tests := []struct {
+		name        string
+		content     []byte
+		contentType string
+	}{
+		{"meta charset", encoded, "text/html"},
+		{"content type", encode(`<p>Привет</p><a href="страница.html">ссылка</a>`), "text/html; charset=windows-1251"},
+		{"utf-8", []byte(page), "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		got := string(toUTF8(tt.content, tt.contentType))
+		if !strings.Contains(got, "<p>Привет</p>") || !strings.Contains(got, `href="страница.html"`) {
+			t.Errorf("%s: toUTF8 = %q", tt.name, got)
+		}
+	}
+
+	// После переписывания ссылок страница остаётся в исходной кодировке
+	updated, err := rewriteResource(encoded, "text/html", func(href string, resource bool) string {
+		return "other.html"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := encode(`<meta charset="windows-1251"><p>Привет</p><a href="other.html">ссылка</a>`); !bytes.Equal(updated, want) {
+		t.Errorf("rewritten page %q", updated)
+	}
+	// Символы, которых нет в кодировке страницы, записываются ссылками на символы
+	updated, err = rewriteResource(encoded, "text/html", func(href string, resource bool) string {
+		return "日本.html"
+	})
+	if err != nil || !bytes.Contains(updated, []byte(`href="&#26085;&#26412;.html"`)) {
+		t.Errorf("rewritten page %q, %v", updated, err)
+	}
+}
+
+func TestCrawlDecodesCompressedLegacyPage(t *testing.T) {
+	page, _ := charmap.Windows1251.NewEncoder().Bytes([]byte(`<meta charset="windows-1251"><p>Привет</p><a href="страница.html">ссылка</a> <img src="img.png">`))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "br")
+			w.Write(compress(t, "br", page))
+		case "/страница.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("page"))
+		case "/img.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write(compress(t, "zstd", []byte("PNG")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, storage := runCrawl(t, Options{URL: srv.URL + "/", MaxDepth: 1})
+
+	host := hostPrefix(srv)
+	// Сохраняются распакованные исходные байты страницы
+	if got := fileString(t, storage, host+"/index.html"); got != string(page) {
+		t.Errorf("saved page %q", got)
+	}
+	if got := fileString(t, storage, host+"/img.png"); got != "PNG" {
+		t.Errorf("saved image %q", got)
+	}
+	// Ссылка в windows-1251 найдена и запрошена
+	if !storage.Exists(host + "/страница.html") {
+		t.Errorf("cyrillic link not followed: %v", storage.Names())
+	}
+	if n := c.Failures().Len(); n != 0 {
+		t.Errorf("%d failures", n)
+	}
+}
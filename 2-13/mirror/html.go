@@ -0,0 +1,397 @@
+package mirror
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// linkRelPattern атрибут rel в кавычках или без них
+var linkRelPattern = regexp.MustCompile(`(?i)\srel\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// requisiteRels значения rel у <link>, без которых страница отображается
+// не полностью. Остальные (next, prev, alternate, canonical...) — переходы.
+var requisiteRels = map[string]bool{
+	"stylesheet":                   true,
+	"icon":                         true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"mask-icon":                    true,
+	"manifest":                     true,
+	"preload":                      true,
+	"modulepreload":                true,
+}
+
+// isRequisiteLink сообщает, ссылается ли тег <link> на ресурс страницы
+func isRequisiteLink(tagContent string) bool {
+	m := linkRelPattern.FindStringSubmatch(tagContent)
+	if m == nil {
+		return false
+	}
+	for _, rel := range strings.Fields(strings.ToLower(m[1] + m[2] + m[3])) {
+		if requisiteRels[rel] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHTMLSimple парсит HTML и извлекает переходы, ресурсы и фреймы.
+// Фреймы (iframe, frame, embed) входят и в ресурсы: это документы,
+// которые в режиме PageRequisites тоже разбираются.
+func parseHTMLSimple(content []byte, baseURL *url.URL) (links, resources, frames []string) {
+	contentStr := string(content)
+
+	// Ищем ссылки в <a> тегах
+	links = append(links, extractLinks(contentStr, "a", "href", baseURL)...)
+
+	// <link> — ресурс или переход в зависимости от rel
+	forEachAttr(contentStr, "link", "href", func(tagContent, href string) {
+		absoluteURL := resolveURL(href, baseURL)
+		if absoluteURL == "" {
+			return
+		}
+		if isRequisiteLink(tagContent) {
+			resources = append(resources, absoluteURL)
+		} else {
+			links = append(links, absoluteURL)
+		}
+	})
+
+	// Ищем фреймы и ресурсы
+	frames = append(frames, extractLinks(contentStr, "iframe", "src", baseURL)...)
+	frames = append(frames, extractLinks(contentStr, "frame", "src", baseURL)...)
+	frames = append(frames, extractLinks(contentStr, "embed", "src", baseURL)...)
+	resources = append(resources, frames...)
+	resources = append(resources, extractLinks(contentStr, "script", "src", baseURL)...)
+	resources = append(resources, extractLinks(contentStr, "img", "src", baseURL)...)
+	resources = append(resources, extractLinks(contentStr, "source", "src", baseURL)...)
+	resources = append(resources, extractLinks(contentStr, "video", "poster", baseURL)...)
+	resources = append(resources, extractSrcset(contentStr, "img", baseURL)...)
+	resources = append(resources, extractSrcset(contentStr, "source", baseURL)...)
+
+	// Картинки и шрифты из <style> и атрибутов style
+	rewriteInlineStyles(contentStr, func(css string) string {
+		resources = append(resources, extractCSSLinks(css, baseURL)...)
+		return css
+	})
+
+	// Убираем дубликаты
+	links = removeDuplicates(links)
+	resources = removeDuplicates(resources)
+	frames = removeDuplicates(frames)
+
+	return links, resources, frames
+}
+
+// extractLinks извлекает ссылки из HTML
+func extractLinks(content, tag, attr string, baseURL *url.URL) []string {
+	var results []string
+	for _, href := range extractAttrValues(content, tag, attr) {
+		if absoluteURL := resolveURL(href, baseURL); absoluteURL != "" {
+			results = append(results, absoluteURL)
+		}
+	}
+	return results
+}
+
+// extractSrcset извлекает адреса кандидатов из атрибута srcset
+func extractSrcset(content, tag string, baseURL *url.URL) []string {
+	var results []string
+	for _, value := range extractAttrValues(content, tag, "srcset") {
+		replaceSrcset(value, func(href string) string {
+			if absoluteURL := resolveURL(href, baseURL); absoluteURL != "" {
+				results = append(results, absoluteURL)
+			}
+			return href
+		})
+	}
+	return results
+}
+
+// replaceSrcset заменяет адреса в srcset вида "a.jpg 1x, b.jpg 2x"
+// с помощью convert, сохраняя дескрипторы
+func replaceSrcset(value string, convert func(string) string) string {
+	candidates := strings.Split(value, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 || !isValidLink(fields[0]) {
+			continue
+		}
+		candidates[i] = strings.Replace(candidate, fields[0], convert(fields[0]), 1)
+	}
+	return strings.Join(candidates, ",")
+}
+
+// extractAttrValues возвращает значения атрибута attr в тегах tag,
+// пропуская якоря и псевдоссылки
+func extractAttrValues(content, tag, attr string) []string {
+	var results []string
+	forEachAttr(content, tag, attr, func(_, value string) {
+		results = append(results, value)
+	})
+	return results
+}
+
+// forEachAttr вызывает fn для каждого тега tag с атрибутом attr,
+// передавая текст тега и значение атрибута; якоря и псевдоссылки пропускаются
+func forEachAttr(content, tag, attr string, fn func(tagContent, value string)) {
+	pos := 0
+	for {
+		start, end, vs, ve, ok := nextTagAttr(content, tag, attr, pos)
+		if !ok {
+			return
+		}
+		if vs != -1 && isValidLink(content[vs:ve]) {
+			fn(content[start:end], content[vs:ve])
+		}
+		pos = end
+	}
+}
+
+// nextTagAttr ищет с позиции pos тег tag и его атрибут attr. Возвращает
+// границы текста тега от '<' до '>' (не включая) и границы значения
+// атрибута; vs == -1, если атрибута нет или у него нет значения.
+func nextTagAttr(content, tag, attr string, pos int) (start, end, vs, ve int, ok bool) {
+	for {
+		i := strings.IndexByte(content[pos:], '<')
+		if i == -1 {
+			return 0, 0, 0, 0, false
+		}
+		start = pos + i
+		pos = start + 1
+
+		// Имя тега целиком: <a не должен совпадать с <abbr или <audio
+		nameEnd := pos + len(tag)
+		if nameEnd > len(content) || !strings.EqualFold(content[pos:nameEnd], tag) {
+			continue
+		}
+		if nameEnd < len(content) && !isSpace(content[nameEnd]) && content[nameEnd] != '/' && content[nameEnd] != '>' {
+			continue
+		}
+
+		vs, ve = -1, -1
+		found := false
+		end = scanAttrs(content, nameEnd, func(name string, s, e int) {
+			// Повторный атрибут игнорируется, как в браузере
+			if !found && strings.EqualFold(name, attr) {
+				found = true
+				vs, ve = s, e
+			}
+		})
+		if end == -1 {
+			return 0, 0, 0, 0, false
+		}
+		return start, end, vs, ve, true
+	}
+}
+
+// scanAttrs разбирает атрибуты тега начиная с pos и вызывает fn с именем
+// и границами значения каждого; у атрибута без значения границы равны -1.
+// Значение может быть в двойных, одинарных кавычках или без них.
+// Возвращает позицию закрывающего '>' или -1, если тег не закрыт.
+func scanAttrs(content string, pos int, fn func(name string, start, end int)) int {
+	for pos < len(content) {
+		c := content[pos]
+		if c == '>' {
+			return pos
+		}
+		if isSpace(c) || c == '/' || c == '=' {
+			pos++
+			continue
+		}
+
+		nameStart := pos
+		for pos < len(content) && !isSpace(content[pos]) && !strings.ContainsRune("/=>", rune(content[pos])) {
+			pos++
+		}
+		name := content[nameStart:pos]
+		for pos < len(content) && isSpace(content[pos]) {
+			pos++
+		}
+		if pos >= len(content) || content[pos] != '=' {
+			fn(name, -1, -1)
+			continue
+		}
+		pos++
+		for pos < len(content) && isSpace(content[pos]) {
+			pos++
+		}
+		if pos >= len(content) {
+			break
+		}
+
+		if q := content[pos]; q == '"' || q == '\'' {
+			end := strings.IndexByte(content[pos+1:], q)
+			if end == -1 {
+				return -1
+			}
+			fn(name, pos+1, pos+1+end)
+			pos += end + 2
+			continue
+		}
+		start := pos
+		for pos < len(content) && !isSpace(content[pos]) && content[pos] != '>' {
+			pos++
+		}
+		fn(name, start, pos)
+	}
+	return -1
+}
+
+// isSpace пробельный символ HTML
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// quoteAttr готовит новое значение атрибута для записи на место старого:
+// экранирует кавычку, в которую оно заключено, а значение без кавычек,
+// которое так записать нельзя, заключает в двойные кавычки
+func quoteAttr(value string, quote byte) string {
+	switch quote {
+	case '"':
+		return strings.ReplaceAll(value, `"`, "&quot;")
+	case '\'':
+		return strings.ReplaceAll(value, "'", "&#39;")
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\r\f\"'=<>`") {
+		return `"` + strings.ReplaceAll(value, `"`, "&quot;") + `"`
+	}
+	return value
+}
+
+// isValidLink проверяет, является ли ссылка валидной для скачивания
+func isValidLink(link string) bool {
+	return link != "" &&
+		!strings.HasPrefix(link, "#") &&
+		!strings.HasPrefix(strings.ToLower(link), "javascript:") &&
+		!strings.HasPrefix(strings.ToLower(link), "mailto:") &&
+		!strings.HasPrefix(strings.ToLower(link), "data:")
+}
+
+// resolveURL преобразует относительный URL в абсолютный
+func resolveURL(href string, baseURL *url.URL) string {
+	if href == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+
+	// Абсолютные URL
+	if parsed.IsAbs() {
+		return parsed.String()
+	}
+
+	// Относительные URL
+	resolved := baseURL.ResolveReference(parsed)
+	return resolved.String()
+}
+
+// removeDuplicates удаляет дубликаты из среза
+func removeDuplicates(slice []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+
+	for _, item := range slice {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// isHTMLContent проверяет, является ли контент HTML
+func isHTMLContent(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "text/html") ||
+		strings.Contains(strings.ToLower(contentType), "application/xhtml+xml")
+}
+
+// replaceLinksSimple заменяет ссылки в HTML с помощью convert. Вторым
+// аргументом convert получает false для переходов по <a> и true для
+// ресурсов страницы.
+func replaceLinksSimple(contentStr string, convert func(href string, resource bool) string) string {
+	contentStr = replaceLinksInTag(contentStr, "a", "href", func(href string) string {
+		return convert(href, false)
+	})
+	contentStr = replaceAttrInTag(contentStr, "link", "href", func(tagContent, href string) string {
+		return convert(href, isRequisiteLink(tagContent))
+	})
+
+	// Заменяем ссылки на ресурсы в разных тегах
+	tags := []struct {
+		tag  string
+		attr string
+	}{
+		{"script", "src"},
+		{"img", "src"},
+		{"iframe", "src"},
+		{"frame", "src"},
+		{"embed", "src"},
+		{"source", "src"},
+		{"video", "poster"},
+	}
+
+	convertResource := func(href string) string {
+		return convert(href, true)
+	}
+	for _, t := range tags {
+		contentStr = replaceLinksInTag(contentStr, t.tag, t.attr, convertResource)
+	}
+
+	convertSrcset := func(value string) string {
+		return replaceSrcset(value, convertResource)
+	}
+	contentStr = replaceLinksInTag(contentStr, "img", "srcset", convertSrcset)
+	contentStr = replaceLinksInTag(contentStr, "source", "srcset", convertSrcset)
+
+	return rewriteInlineStyles(contentStr, func(css string) string {
+		return replaceCSSLinks(css, convertResource)
+	})
+}
+
+// replaceLinksInTag заменяет ссылки в конкретном теге с помощью convert
+func replaceLinksInTag(content, tag, attr string, convert func(string) string) string {
+	return replaceAttrInTag(content, tag, attr, func(_, value string) string {
+		return convert(value)
+	})
+}
+
+// replaceAttrInTag заменяет значения атрибута attr в тегах tag; convert
+// получает текст тега, чтобы учитывать соседние атрибуты
+func replaceAttrInTag(content, tag, attr string, convert func(tagContent, value string) string) string {
+	var result strings.Builder
+	pos, written := 0, 0
+	for {
+		start, end, vs, ve, ok := nextTagAttr(content, tag, attr, pos)
+		if !ok {
+			break
+		}
+		pos = end
+		if vs == -1 || !isValidLink(content[vs:ve]) {
+			continue
+		}
+
+		var quote byte
+		if vs > 0 && (content[vs-1] == '"' || content[vs-1] == '\'') {
+			quote = content[vs-1]
+		}
+		result.WriteString(content[written:vs])
+		result.WriteString(quoteAttr(convert(content[start:end], content[vs:ve]), quote))
+		written = ve
+	}
+	result.WriteString(content[written:])
+	return result.String()
+}
+
+// hashContent создает хеш содержимого
+func hashContent(content []byte) string {
+	hash := sha256.Sum256(content)
+	return fmt.Sprintf("%x", hash[:16])
+}
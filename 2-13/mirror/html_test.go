@@ -0,0 +1,140 @@
+package mirror
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseHTMLAttributes(t *testing.T) {
+	tests := []struct {
+		name      string
+		html      string
+		links     []string
+		resources []string
+	}{
+		{"double quotes", `<a href="a.html">`, []string{"a.html"}, nil},
+		{"single quotes", `<a href='a.html'>`, []string{"a.html"}, nil},
+		{"unquoted", `<a href=a.html>`, []string{"a.html"}, nil},
+		{"unquoted with slash", `<a href=/dir/a.html/>`, []string{"dir/a.html/"}, nil},
+		{"spaces around =", "<a\nhref = \"a.html\" >", []string{"a.html"}, nil},
+		{"upper case", `<A HREF="a.html">`, []string{"a.html"}, nil},
+		{"other attribute first", `<img alt="x > y" title='"' src=pic.png>`, nil, []string{"pic.png"}},
+		{"quote inside value", `<a title="it's" href='a.html'>`, []string{"a.html"}, nil},
+		{"prefixed name", `<a data-href="no.html" href="a.html">`, []string{"a.html"}, nil},
+		{"first of duplicates", `<a href="a.html" href="b.html">`, []string{"a.html"}, nil},
+		{"valueless attribute", `<a download href=a.html>`, []string{"a.html"}, nil},
+		{"self-closing", `<img src="pic.png"/><img/src="b.png">`, nil, []string{"pic.png", "b.png"}},
+		{"tag prefix abbr", `<abbr href="no.html">`, nil, nil},
+		{"tag prefix audio", `<audio src="no.mp3"><area href="no.html">`, nil, nil},
+		{"tag prefix linkfoo", `<linkfoo href="no.css" rel="stylesheet">`, nil, nil},
+		{"link stylesheet", `<link rel=stylesheet href=s.css>`, nil, []string{"s.css"}},
+		{"link next", `<link rel='next' href='2.html'>`, []string{"2.html"}, nil},
+		{"pseudo links", `<a href="#top"> <a href='javascript:void(0)'> <a href=mailto:me@example.com>`, nil, nil},
+		{"unclosed tag", `<a href="a.html">ok</a> <a href="b.html"`, []string{"a.html"}, nil},
+		{"unclosed quote", `<a href="a.html>`, nil, nil},
+	}
+	base, _ := url.Parse("http://example.com/")
+	for _, tt := range tests {
+		links, resources, _ := parseHTMLSimple([]byte(tt.html), base)
+		if want := prefixAll("http://example.com/", tt.links); !slices.Equal(links, want) {
+			t.Errorf("%s: links %v, want %v", tt.name, links, want)
+		}
+		if want := prefixAll("http://example.com/", tt.resources); !slices.Equal(resources, want) {
+			t.Errorf("%s: resources %v, want %v", tt.name, resources, want)
+		}
+	}
+}
+
+// prefixAll добавляет prefix к каждой строке; nil даёт пустой срез,
+// как у removeDuplicates
+func prefixAll(prefix string, items []string) []string {
+	result := []string{}
+	for _, item := range items {
+		result = append(result, prefix+item)
+	}
+	return result
+}
+
+func TestParseHTMLFrames(t *testing.T) {
+	base, _ := url.Parse("http://example.com/dir/")
+	_, resources, frames := parseHTMLSimple([]byte(`<iframe src=f.html></iframe><frame src="g.html">
+<embed src='e.svg'><img srcset="a.png 1x, b.png 2x"><video poster=p.jpg>`), base)
+	if want := []string{"http://example.com/dir/f.html", "http://example.com/dir/g.html", "http://example.com/dir/e.svg"}; !slices.Equal(frames, want) {
+		t.Errorf("frames %v", frames)
+	}
+	for _, want := range []string{"a.png", "b.png", "p.jpg"} {
+		if !slices.Contains(resources, "http://example.com/dir/"+want) {
+			t.Errorf("resource %s missing from %v", want, resources)
+		}
+	}
+}
+
+func TestReplaceLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"double quotes", `<a href="a.html">a</a>`, `<a href="A">a</a>`},
+		{"single quotes", `<a href='a.html'>`, `<a href='A'>`},
+		{"unquoted", `<a href=a.html>`, `<a href=A>`},
+		{"unquoted at the end", `<img src=pic.png>`, `<img src=R>`},
+		{"only the attribute", `<img alt="pic.png" src="pic.png">`, `<img alt="pic.png" src="R">`},
+		{"tag prefix", `<abbr href="a.html"><audio src="a.mp3">`, `<abbr href="a.html"><audio src="a.mp3">`},
+		{"link by rel", `<link href=s.css rel=stylesheet><link rel="next" href="2.html">`, `<link href=R rel=stylesheet><link rel="next" href="A">`},
+		{"srcset", `<img srcset='a.png 1x, b.png 2x'>`, `<img srcset='R 1x, R 2x'>`},
+		{"anchor kept", `<a href="#top">`, `<a href="#top">`},
+		{"every tag", `<a href=1><b>x</b><a href='2'>`, `<a href=A><b>x</b><a href='A'>`},
+	}
+	for _, tt := range tests {
+		got := replaceLinksSimple(tt.html, func(href string, resource bool) string {
+			if resource {
+				return "R"
+			}
+			return "A"
+		})
+		if got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceLinksQuotesNewValue(t *testing.T) {
+	tests := []struct {
+		html, value, want string
+	}{
+		{`<a href=x>`, "a b.html", `<a href="a b.html">`},
+		{`<a href=x>`, `say"hi".html`, `<a href="say&quot;hi&quot;.html">`},
+		{`<a href=x>`, "", `<a href="">`},
+		{`<a href="x">`, `q".html`, `<a href="q&quot;.html">`},
+		{`<a href='x'>`, "it's.html", `<a href='it&#39;s.html'>`},
+	}
+	for _, tt := range tests {
+		got := replaceLinksSimple(tt.html, func(string, bool) string { return tt.value })
+		if got != tt.want {
+			t.Errorf("%s with %q: %q, want %q", tt.html, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIsRequisiteLink(t *testing.T) {
+	tests := map[string]bool{
+		`<link rel="stylesheet" href="s.css"`:        true,
+		`<link rel='Shortcut Icon' href="f.ico"`:      true,
+		`<link rel=preload href="f.woff"`:             true,
+		`<link rel="alternate" href="feed.xml"`:       false,
+		`<link href="x"`:                              false,
+		`<link data-rel="stylesheet" href="x.css"`:    false,
+		`<link rel="next" title="stylesheet" href=x>`: false,
+	}
+	for tag, want := range tests {
+		if got := isRequisiteLink(tag); got != want {
+			t.Errorf("isRequisiteLink(%s) = %v, want %v", tag, got, want)
+		}
+	}
+	if strings.Contains(replaceSrcset("a.png 1x, data:x 2x", strings.ToUpper), "DATA") {
+		t.Error("replaceSrcset converted a data: URL")
+	}
+}
@@ -0,0 +1,289 @@
+package mirror
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxQueryNameLen максимальная длина query-части в имени файла,
+// более длинные запросы заменяются хешем
+const maxQueryNameLen = 64
+
+// PathMapper назначает ресурсам пути в хранилище и запоминает назначения,
+// чтобы сохранение и переписывание ссылок давали одинаковый результат.
+//
+// Схема имён: <host>[+<port>]/<путь>[@<query>][.html|.css].
+// Пустой путь и путь на "/" дают index.html, HTML и CSS без подходящего
+// расширения получают его по Content-Type (как wget -E).
+// Конфликты разрешаются по самим URL, а не по порядку скачивания, поэтому
+// один и тот же сайт всегда раскладывается одинаково: каталог всегда
+// сохраняет своё имя, из двух файлов с одним именем его получает меньший URL.
+// Уступивший файл получает имя с хешем своего URL перед расширением
+// (page.3f2a9c1b.html); если файл уже сохранён, Assign возвращает перенос.
+type PathMapper struct {
+	mu      sync.Mutex
+	byURL   map[string]string // URL без фрагмента → путь через "/"
+	files   map[string]string // занятые пути файлов → URL
+	dirs    map[string]bool   // занятые пути каталогов
+	aliases map[string]string // исходный URL редиректа → конечный URL
+}
+
+// NewPathMapper создает пустое отображение URL в пути
+func NewPathMapper() *PathMapper {
+	return &PathMapper{
+		byURL:   make(map[string]string),
+		files:   make(map[string]string),
+		dirs:    make(map[string]bool),
+		aliases: make(map[string]string),
+	}
+}
+
+// Register восстанавливает назначение из прошлого запуска
+func (m *PathMapper) Register(urlStr, name string) {
+	rel, ok := cleanName(name)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claim(mapperKey(urlStr), rel)
+}
+
+// Alias отображает URL, отдавший редирект, на файл конечного URL
+func (m *PathMapper) Alias(fromURL, toURL string) {
+	from, to := mapperKey(fromURL), mapperKey(toURL)
+	if from == to {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aliases[from] = to
+}
+
+// Lookup возвращает назначенный URL путь, если он уже есть.
+// Для URL с редиректом возвращается файл конечного URL.
+func (m *PathMapper) Lookup(urlStr string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := mapperKey(urlStr)
+	for i := 0; i < maxRedirects; i++ {
+		to, ok := m.aliases[key]
+		if !ok {
+			break
+		}
+		key = to
+	}
+
+	rel, ok := m.byURL[key]
+	if !ok {
+		return "", false
+	}
+	return filepath.FromSlash(rel), true
+}
+
+// Relocation перенос уже сохранённого файла, который уступил имя
+type Relocation struct {
+	URL      string
+	From, To string // пути в хранилище
+}
+
+// Assign назначает URL путь с учётом Content-Type и уже занятых имён.
+// Повторный вызов для того же URL возвращает прежний путь. Если новый
+// URL вытесняет сохранённые файлы, их нужно перенести до сохранения
+// нового файла: переносы возвращаются в порядке выполнения.
+func (m *PathMapper) Assign(u *url.URL, contentType string) (string, []Relocation) {
+	key := mapperKey(u.String())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rel, ok := m.byURL[key]; ok {
+		return filepath.FromSlash(rel), nil
+	}
+
+	dirs, leaf := splitLocalName(u, contentType)
+	var moves []Relocation
+
+	// Каталоги: файл, занявший имя каталога, уступает его
+	dir := ""
+	for _, d := range dirs {
+		dir = path.Join(dir, d)
+		if owner, isFile := m.files[dir]; isFile {
+			moves = append(moves, m.evict(owner, dir))
+		}
+	}
+
+	// Файл: каталог имя не уступает, из двух файлов имя достаётся меньшему URL
+	rel := path.Join(dir, leaf)
+	owner, isFile := m.files[rel]
+	switch {
+	case m.dirs[rel] || isFile && owner < key:
+		rel = m.fallbackName(rel, key)
+	case isFile:
+		moves = append(moves, m.evict(owner, rel))
+	}
+
+	m.claim(key, rel)
+	return filepath.FromSlash(rel), moves
+}
+
+// evict переносит файл owner с пути rel на запасное имя. Вызывается под m.mu.
+func (m *PathMapper) evict(owner, rel string) Relocation {
+	to := m.fallbackName(rel, owner)
+	delete(m.files, rel)
+	m.claim(owner, to)
+	return Relocation{URL: owner, From: filepath.FromSlash(rel), To: filepath.FromSlash(to)}
+}
+
+// fallbackName возвращает имя для файла URL key, уступившего путь rel:
+// хеш URL перед расширением. Номер добавляется, только если занято и оно.
+func (m *PathMapper) fallbackName(rel, key string) string {
+	dir, leaf := path.Split(rel)
+	ext := path.Ext(leaf)
+	base := strings.TrimSuffix(leaf, ext) + "." + hashContent([]byte(key))[:8]
+
+	name := path.Join(dir, base+ext)
+	for n := 1; m.taken(name, key); n++ {
+		name = path.Join(dir, fmt.Sprintf("%s.%d%s", base, n, ext))
+	}
+	return name
+}
+
+// URLFor возвращает URL, которому назначен файл
+func (m *PathMapper) URLFor(name string) (string, bool) {
+	rel, ok := cleanName(name)
+	if !ok {
+		return "", false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	urlStr, ok := m.files[rel]
+	return urlStr, ok
+}
+
+// cleanName приводит путь к виду через "/" и отбрасывает пути вне хранилища
+func cleanName(name string) (string, bool) {
+	rel := path.Clean(filepath.ToSlash(name))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// taken сообщает, занят ли путь каталогом или файлом другого URL
+func (m *PathMapper) taken(rel, key string) bool {
+	if m.dirs[rel] {
+		return true
+	}
+	owner, ok := m.files[rel]
+	return ok && owner != key
+}
+
+// claim запоминает путь файла и все его родительские каталоги
+func (m *PathMapper) claim(key, rel string) {
+	m.byURL[key] = rel
+	m.files[rel] = key
+	for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+}
+
+// mapperKey нормализует URL для отображения: фрагмент на файл не влияет
+func mapperKey(urlStr string) string {
+	if i := strings.IndexByte(urlStr, '#'); i != -1 {
+		return urlStr[:i]
+	}
+	return urlStr
+}
+
+// splitLocalName разбивает URL на каталоги и имя файла без учёта конфликтов
+func splitLocalName(u *url.URL, contentType string) ([]string, string) {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = sanitizeSegment(seg)
+	}
+
+	dirs := append([]string{hostDir(u)}, segments[:len(segments)-1]...)
+	leaf := segments[len(segments)-1]
+
+	// Разные query дают разные файлы
+	if u.RawQuery != "" {
+		query := sanitizeSegment(u.RawQuery)
+		if len(query) > maxQueryNameLen {
+			query = hashContent([]byte(u.RawQuery))
+		}
+		leaf += "@" + query
+	}
+
+	return dirs, leaf + extensionFor(leaf, contentType)
+}
+
+// extensionFor возвращает расширение, которое нужно добавить по Content-Type
+func extensionFor(name, contentType string) string {
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case isHTMLContent(contentType):
+		if ext != ".html" && ext != ".htm" && ext != ".xhtml" {
+			return ".html"
+		}
+	case strings.Contains(strings.ToLower(contentType), "text/css"):
+		if ext != ".css" {
+			return ".css"
+		}
+	}
+	return ""
+}
+
+// hostDir возвращает имя каталога для хоста; нестандартный порт входит в имя
+func hostDir(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" {
+		host += "+" + port
+	}
+	return sanitizeSegment(host)
+}
+
+// sanitizeSegment кодирует символы, недопустимые в именах файлов
+func sanitizeSegment(seg string) string {
+	if seg == "" || seg == "." || seg == ".." {
+		return "_" + seg
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte(`\/:*?"<>|%#`, c) != -1 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// localHref превращает относительный путь файла в значение href
+func localHref(rel string) string {
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, seg := range segments {
+		if seg == "." || seg == ".." {
+			continue
+		}
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,113 @@
+package mirror
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathMapperNames(t *testing.T) {
+	long := strings.Repeat("x", maxQueryNameLen+1)
+	tests := []struct {
+		url, contentType, want string
+	}{
+		{"http://example.com", "text/html", "example.com/index.html"},
+		{"http://example.com/", "text/html", "example.com/index.html"},
+		{"http://example.com/docs/", "text/html", "example.com/docs/index.html"},
+		{"http://Example.COM:8080/a.html", "text/html", "example.com+8080/a.html"},
+		// -E: HTML и CSS получают расширение по Content-Type
+		{"http://example.com/page", "text/html; charset=utf-8", "example.com/page.html"},
+		{"http://example.com/page.htm", "text/html", "example.com/page.htm"},
+		{"http://example.com/page.php", "text/html", "example.com/page.php.html"},
+		{"http://example.com/style", "text/css", "example.com/style.css"},
+		{"http://example.com/data.json", "application/json", "example.com/data.json"},
+		// Query входит в имя, длинный заменяется хешем
+		{"http://example.com/list?page=2", "text/html", "example.com/list@page=2.html"},
+		{"http://example.com/a.png?v=1", "image/png", "example.com/a.png@v=1"},
+		{"http://example.com/s?q=a/b", "text/plain", "example.com/s@q=a%2Fb"},
+		{"http://example.com/s?" + long, "text/plain", "example.com/s@" + hashContent([]byte(long))},
+		// Фрагмент на файл не влияет, недопустимые символы кодируются
+		{"http://example.com/a.html#top", "text/html", "example.com/a.html"},
+		{"http://example.com/a:b", "text/plain", "example.com/a%3Ab"},
+		{"http://example.com/../x", "text/plain", "example.com/x"},
+	}
+	for _, tt := range tests {
+		m := NewPathMapper()
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, moves := m.Assign(u, tt.contentType)
+		if filepath.ToSlash(got) != tt.want || moves != nil {
+			t.Errorf("Assign(%s, %s) = %s, %v; want %s", tt.url, tt.contentType, got, moves, tt.want)
+		}
+	}
+}
+
+func TestPathMapperConflicts(t *testing.T) {
+	type assign struct{ url, contentType string }
+	// Файл /a и каталог /a/, два URL с одним именем после -E
+	urls := []assign{
+		{"http://example.com/a", "text/plain"},
+		{"http://example.com/a/b.png", "image/png"},
+		{"http://example.com/p", "text/html"},
+		{"http://example.com/p.html", "text/html"},
+	}
+	hash := func(u string) string { return hashContent([]byte(u))[:8] }
+	want := map[string]string{
+		"http://example.com/a":       "example.com/a." + hash("http://example.com/a"),
+		"http://example.com/a/b.png": "example.com/a/b.png",
+		"http://example.com/p":       "example.com/p.html",
+		"http://example.com/p.html":  "example.com/p." + hash("http://example.com/p.html") + ".html",
+	}
+
+	// Раскладка не зависит от порядка скачивания
+	for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {1, 0, 3, 2}} {
+		m := NewPathMapper()
+		saved := make(map[string]string)
+		for _, i := range order {
+			u, _ := url.Parse(urls[i].url)
+			name, moves := m.Assign(u, urls[i].contentType)
+			for _, mv := range moves {
+				if saved[mv.URL] != filepath.ToSlash(mv.From) {
+					t.Errorf("order %v: move %+v, but %s is at %s", order, mv, mv.URL, saved[mv.URL])
+				}
+				saved[mv.URL] = filepath.ToSlash(mv.To)
+			}
+			saved[urls[i].url] = filepath.ToSlash(name)
+		}
+		for u, name := range want {
+			if saved[u] != name {
+				t.Errorf("order %v: %s at %s, want %s", order, u, saved[u], name)
+			}
+			if got, _ := m.Lookup(u); filepath.ToSlash(got) != name {
+				t.Errorf("order %v: Lookup(%s) = %s, want %s", order, u, got, name)
+			}
+		}
+	}
+}
+
+func TestPathMapperRegisterAndAlias(t *testing.T) {
+	m := NewPathMapper()
+	m.Register("http://example.com/", "example.com/index.html")
+	m.Register("http://example.com/bad", "../outside")
+	m.Alias("http://example.com/old", "http://example.com/")
+
+	if got, ok := m.Lookup("http://example.com/old#x"); !ok || filepath.ToSlash(got) != "example.com/index.html" {
+		t.Errorf("Lookup through alias = %s, %v", got, ok)
+	}
+	if _, ok := m.Lookup("http://example.com/bad"); ok {
+		t.Error("path outside the storage was registered")
+	}
+	if got, ok := m.URLFor(filepath.FromSlash("example.com/./index.html")); !ok || got != "http://example.com/" {
+		t.Errorf("URLFor = %s, %v", got, ok)
+	}
+
+	// Имя из прошлого запуска остаётся за меньшим URL, новый получает хеш
+	u, _ := url.Parse("http://example.com/index.html")
+	want := "example.com/index." + hashContent([]byte(u.String()))[:8] + ".html"
+	if got, moves := m.Assign(u, "text/html"); filepath.ToSlash(got) != want || moves != nil {
+		t.Errorf("Assign = %s, %v; want %s", got, moves, want)
+	}
+}
@@ -0,0 +1,167 @@
+package mirror
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ScopePolicy решает, какие URL входят в область зеркалирования.
+// Одни и те же правила применяются и к страницам, и к ресурсам.
+type ScopePolicy struct {
+	StartURL       *url.URL
+	SpanHosts      bool     // разрешить любые хосты (с учётом Domains)
+	Domains        []string // разрешённые домены вместе с поддоменами
+	ExcludeDomains []string // запрещённые домены вместе с поддоменами
+	NoParent       bool     // не подниматься выше каталога стартового URL
+	AcceptRegex    *regexp.Regexp
+	RejectRegex    *regexp.Regexp
+	Accept         []string // разрешённые расширения/суффиксы имён файлов
+	Reject         []string // запрещённые расширения/суффиксы имён файлов
+}
+
+// NewScopePolicy создает политику из значений флагов командной строки
+func NewScopePolicy(startURL *url.URL, spanHosts, noParent bool,
+	domains, excludeDomains, accept, reject, acceptRegex, rejectRegex string) (*ScopePolicy, error) {
+	policy := &ScopePolicy{
+		StartURL:       startURL,
+		SpanHosts:      spanHosts,
+		NoParent:       noParent,
+		Domains:        splitList(strings.ToLower(domains)),
+		ExcludeDomains: splitList(strings.ToLower(excludeDomains)),
+		Accept:         splitList(strings.ToLower(accept)),
+		Reject:         splitList(strings.ToLower(reject)),
+	}
+
+	var err error
+	if acceptRegex != "" {
+		if policy.AcceptRegex, err = regexp.Compile(acceptRegex); err != nil {
+			return nil, fmt.Errorf("некорректный -accept-regex: %w", err)
+		}
+	}
+	if rejectRegex != "" {
+		if policy.RejectRegex, err = regexp.Compile(rejectRegex); err != nil {
+			return nil, fmt.Errorf("некорректный -reject-regex: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// Allow проверяет URL. Для страниц (page == true) список -A не применяется,
+// иначе обход не смог бы пройти через HTML к нужным файлам.
+func (p *ScopePolicy) Allow(u *url.URL, page bool) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if !p.allowHost(strings.ToLower(u.Hostname())) {
+		return false
+	}
+	if p.NoParent && !p.underStart(u) {
+		return false
+	}
+	return p.allowName(u, page)
+}
+
+// AllowRequisite проверяет ресурс страницы в режиме -p: картинки, стили
+// и скрипты нужны странице, где бы они ни лежали, поэтому стартовый хост,
+// -span-hosts, -domains и -no-parent не учитываются. Остальные фильтры
+// действуют как в Allow.
+func (p *ScopePolicy) AllowRequisite(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if matchesDomain(strings.ToLower(u.Hostname()), p.ExcludeDomains) {
+		return false
+	}
+	return p.allowName(u, false)
+}
+
+// allowName проверяет URL по регулярным выражениям и спискам расширений
+func (p *ScopePolicy) allowName(u *url.URL, page bool) bool {
+	full := u.String()
+	if p.AcceptRegex != nil && !p.AcceptRegex.MatchString(full) {
+		return false
+	}
+	if p.RejectRegex != nil && p.RejectRegex.MatchString(full) {
+		return false
+	}
+
+	name := strings.ToLower(path.Base(u.Path))
+	if matchesSuffix(name, p.Reject) {
+		return false
+	}
+	if !page && len(p.Accept) > 0 && !matchesSuffix(name, p.Accept) {
+		return false
+	}
+	return true
+}
+
+// allowHost проверяет хост по стартовому хосту, -span-hosts и спискам доменов
+func (p *ScopePolicy) allowHost(host string) bool {
+	if matchesDomain(host, p.ExcludeDomains) {
+		return false
+	}
+	if host == strings.ToLower(p.StartURL.Hostname()) {
+		return true
+	}
+	if len(p.Domains) > 0 {
+		return matchesDomain(host, p.Domains)
+	}
+	return p.SpanHosts
+}
+
+// underStart проверяет, что URL лежит не выше каталога стартового URL.
+// Ограничение действует только на стартовом хосте.
+func (p *ScopePolicy) underStart(u *url.URL) bool {
+	if !strings.EqualFold(u.Hostname(), p.StartURL.Hostname()) {
+		return true
+	}
+	dir := p.StartURL.Path
+	if dir == "" {
+		return true
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+		if !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+	}
+	return strings.HasPrefix(u.Path, dir) || u.Path+"/" == dir
+}
+
+// matchesDomain проверяет совпадение хоста с доменом или его поддоменом
+func matchesDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.TrimPrefix(d, ".")
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSuffix проверяет имя файла по списку расширений вида "jpg" или ".jpg"
+func matchesSuffix(name string, suffixes []string) bool {
+	for _, s := range suffixes {
+		if !strings.HasPrefix(s, ".") {
+			s = "." + s
+		}
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
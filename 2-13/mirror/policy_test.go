@@ -0,0 +1,116 @@
+package mirror
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestScopePolicyAllow(t *testing.T) {
+	type flags struct {
+		spanHosts, noParent                                        bool
+		domains, exclude, accept, reject, acceptRegex, rejectRegex string
+	}
+	tests := []struct {
+		name  string
+		flags flags
+		url   string
+		page  bool
+		want  bool
+	}{
+		{"start host", flags{}, "http://example.com/x.png", false, true},
+		{"host case", flags{}, "http://EXAMPLE.com/x", true, true},
+		{"other host", flags{}, "http://cdn.example.net/x.png", false, false},
+		{"scheme", flags{}, "ftp://example.com/x", true, false},
+		{"mailto", flags{}, "mailto:me@example.com", true, false},
+		{"span hosts", flags{spanHosts: true}, "http://cdn.example.net/x.png", false, true},
+		{"domains", flags{domains: "example.net"}, "http://cdn.example.net/x", true, true},
+		{"domains with dot", flags{domains: ".example.net"}, "http://example.net/x", true, true},
+		{"domains suffix only", flags{domains: "example.net"}, "http://badexample.net/x", true, false},
+		{"exclude", flags{spanHosts: true, exclude: "ads.example.net"}, "http://x.ads.example.net/x", true, false},
+		{"exclude start host", flags{exclude: "example.com"}, "http://example.com/x", true, false},
+		{"no parent below", flags{noParent: true}, "http://example.com/docs/a/b.html", true, true},
+		{"no parent dir itself", flags{noParent: true}, "http://example.com/docs", true, true},
+		{"no parent above", flags{noParent: true}, "http://example.com/other.html", true, false},
+		{"no parent other host", flags{noParent: true, spanHosts: true}, "http://example.net/", true, true},
+		{"accept resource", flags{accept: "png,.jpg"}, "http://example.com/a.JPG", false, true},
+		{"accept rejects resource", flags{accept: "png"}, "http://example.com/a.css", false, false},
+		{"accept ignores pages", flags{accept: "png"}, "http://example.com/docs/", true, true},
+		{"reject", flags{reject: "zip"}, "http://example.com/a.zip", true, false},
+		{"accept regex", flags{acceptRegex: `/docs/`}, "http://example.com/blog/", true, false},
+		{"reject regex", flags{rejectRegex: `\?sort=`}, "http://example.com/docs/?sort=asc", true, false},
+	}
+	start, _ := url.Parse("http://example.com/docs/index.html")
+	for _, tt := range tests {
+		f := tt.flags
+		p, err := NewScopePolicy(start, f.spanHosts, f.noParent, f.domains, f.exclude, f.accept, f.reject, f.acceptRegex, f.rejectRegex)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Allow(u, tt.page); got != tt.want {
+			t.Errorf("%s: Allow(%s, %v) = %v, want %v", tt.name, tt.url, tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestScopePolicyAllowRequisite(t *testing.T) {
+	start, _ := url.Parse("http://example.com/docs/")
+	p, err := NewScopePolicy(start, false, true, "", "tracker.net", "", "exe", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string]bool{
+		"http://cdn.example.net/style.css": true, // другой хост
+		"http://example.com/static/a.png":  true, // выше каталога старта
+		"http://tracker.net/pixel.gif":     false,
+		"http://cdn.example.net/setup.exe": false,
+		"data:image/png;base64,AAAA":       false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := p.AllowRequisite(u); got != want {
+			t.Errorf("AllowRequisite(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestScopePolicyBadRegex(t *testing.T) {
+	start, _ := url.Parse("http://example.com/")
+	if _, err := NewScopePolicy(start, false, false, "", "", "", "", "(", ""); err == nil {
+		t.Error("invalid -accept-regex accepted")
+	}
+	if _, err := NewScopePolicy(start, false, false, "", "", "", "", "", "["); err == nil {
+		t.Error("invalid -reject-regex accepted")
+	}
+}
+
+func TestCrawlStaysInScope(t *testing.T) {
+	other, otherLog := newTestSite(t, map[string]testPage{
+		"/page.html": {Body: "other"},
+		"/a.png":     {Body: "PNG"},
+	})
+	// Тот же сервер под другим именем хоста
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv, log := newTestSite(t, map[string]testPage{
+		"/docs/":         {Body: `<a href="/blog/">up</a> <a href="sub.html">sub</a> <a href="` + otherURL + `/page.html">x</a> <img src="` + otherURL + `/a.png">`},
+		"/docs/sub.html": {Body: "sub"},
+		"/blog/":         {Body: "blog"},
+	})
+
+	start, _ := url.Parse(srv.URL + "/docs/")
+	scope, err := NewScopePolicy(start, false, true, "", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCrawl(t, Options{URL: start.String(), MaxDepth: 2, Scope: scope})
+
+	if got := log.paths(); len(got) != 2 || got[0] != "/docs/" || got[1] != "/docs/sub.html" {
+		t.Errorf("requested %v", got)
+	}
+	if got := otherLog.paths(); len(got) != 0 {
+		t.Errorf("other host requested %v", got)
+	}
+}
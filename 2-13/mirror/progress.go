@@ -0,0 +1,154 @@
+package mirror
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress собирает статистику загрузки и выводит её одной обновляемой строкой.
+// Если вывод не терминал, строка прогресса печатается только в конце,
+// а сообщения — обычными строками без управляющих последовательностей.
+type Progress struct {
+	out     io.Writer
+	tty     bool       // out — терминал, строку можно перерисовывать
+	mu      sync.Mutex // сериализует вывод
+	started time.Time
+
+	bytes   atomic.Int64
+	files   atomic.Int64
+	errors  atomic.Int64
+	pending atomic.Int64
+}
+
+// NewProgress создает индикатор, пишущий в out
+func NewProgress(out io.Writer) *Progress {
+	return &Progress{out: out, tty: isTerminal(out), started: time.Now()}
+}
+
+// isTerminal сообщает, выводит ли w на терминал
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// clearLine возвращает начало вывода поверх строки прогресса
+func (p *Progress) clearLine() string {
+	if p.tty {
+		return "\r\033[K"
+	}
+	return ""
+}
+
+// AddBytes учитывает скачанные байты
+func (p *Progress) AddBytes(n int64) { p.bytes.Add(n) }
+
+// Bytes возвращает общее число скачанных байт
+func (p *Progress) Bytes() int64 { return p.bytes.Load() }
+
+// FileDone учитывает сохранённый файл
+func (p *Progress) FileDone() { p.files.Add(1) }
+
+// Failed учитывает ошибку
+func (p *Progress) Failed() { p.errors.Add(1) }
+
+// Queued изменяет число задач в очереди и в работе
+func (p *Progress) Queued(delta int64) { p.pending.Add(delta) }
+
+// Logf выводит сообщение отдельной строкой, не ломая строку прогресса
+func (p *Progress) Logf(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, p.clearLine()+format+"\n", args...)
+}
+
+// Run обновляет строку прогресса, пока не закрыт stop
+func (p *Progress) Run(stop <-chan struct{}) {
+	if !p.tty {
+		return
+	}
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.print()
+		}
+	}
+}
+
+// Finish выводит итоговую строку прогресса и переводит строку
+func (p *Progress) Finish() {
+	p.print()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.out)
+}
+
+// print выводит текущую строку прогресса
+func (p *Progress) print() {
+	elapsed := time.Since(p.started).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.bytes.Load()) / elapsed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, p.clearLine()+"Файлов: %d, скачано: %s, скорость: %s/с, в очереди: %d, ошибок: %d",
+		p.files.Load(), formatSize(float64(p.bytes.Load())), formatSize(rate),
+		p.pending.Load(), p.errors.Load())
+}
+
+// formatSize форматирует размер в человекочитаемом виде
+func formatSize(n float64) string {
+	units := []string{"Б", "КБ", "МБ", "ГБ", "ТБ"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+// ParseSize разбирает размер вида "512", "100k", "10M", "2G" (0 — без ограничения)
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'k':
+		multiplier = 1 << 10
+	case 'm':
+		multiplier = 1 << 20
+	case 'g':
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("некорректный размер %q", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,84 @@
+package mirror
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProgressPlainOutput(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgress(&out)
+	p.AddBytes(2048)
+	p.FileDone()
+	p.Logf("Ошибка скачивания %s", "http://example.com/")
+
+	// Вне терминала Run ничего не перерисовывает
+	stop := make(chan struct{})
+	close(stop)
+	p.Run(stop)
+	p.Finish()
+
+	got := out.String()
+	if strings.ContainsAny(got, "\r\033") {
+		t.Errorf("escape sequences in non-terminal output: %q", got)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 2 || lines[0] != "Ошибка скачивания http://example.com/" || !strings.HasPrefix(lines[1], "Файлов: 1, скачано: 2.0 КБ") {
+		t.Errorf("output %q", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"", 0, true},
+		{"512", 512, true},
+		{"100k", 100 << 10, true},
+		{" 10M ", 10 << 20, true},
+		{"2G", 2 << 30, true},
+		{"-1", 0, false},
+		{"k", 0, false},
+		{"1.5M", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestQuotaStopsDownload(t *testing.T) {
+	const quota = 64 << 10
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/":        {Body: `<img src="big.png">`},
+		"/big.png": {Body: strings.Repeat("x", 1<<20)},
+	})
+
+	var progress bytes.Buffer
+	c, storage := runCrawl(t, Options{
+		URL:      srv.URL + "/",
+		MaxDepth: 1,
+		Workers:  1,
+		Quota:    quota,
+		Progress: &progress,
+	})
+
+	if storage.Exists(hostPrefix(srv) + "/big.png") {
+		t.Error("big.png saved past the quota")
+	}
+	// Загрузка обрывается на первом буфере после квоты
+	if got := c.progress.Bytes(); got > quota+64<<10 {
+		t.Errorf("downloaded %d bytes with quota %d", got, quota)
+	}
+	if c.Failures().Len() == 0 {
+		t.Error("quota overrun not reported")
+	}
+	if !strings.Contains(progress.String(), errQuotaExceeded.Error()) {
+		t.Errorf("no quota message in %q", progress.String())
+	}
+}
@@ -0,0 +1,58 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxRedirects сколько редиректов подряд разрешено пройти
+const maxRedirects = 10
+
+// errRedirectOutOfScope редирект ведёт за пределы области обхода
+var errRedirectOutOfScope = errors.New("редирект за пределы области обхода")
+
+// redirectChainKey ключ контекста запроса, под которым лежит цепочка редиректов
+type redirectChainKey struct{}
+
+// redirectPageKey ключ контекста запроса: true, если запрошен переход
+// (страница), false — ресурс страницы
+type redirectPageKey struct{}
+
+// withRedirectPage отмечает, проверять ли редиректы запроса как переход
+// или как ресурс страницы
+func withRedirectPage(req *http.Request, page bool) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), redirectPageKey{}, page))
+}
+
+// withRedirectChain привязывает к запросу срез, куда CheckRedirect запишет
+// адреса всех переходов
+func withRedirectChain(req *http.Request, chain *[]string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), redirectChainKey{}, chain))
+}
+
+// checkRedirect возвращает CheckRedirect для http.Client: записывает цепочку
+// и отклоняет переходы за пределы области обхода по той же политике,
+// что и ссылки: в режиме PageRequisites ресурсы могут уводить на другие
+// хосты (CDN). Промежуточные ответы попадают в WARC через warcTransport.
+func (c *Crawler) checkRedirect() func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if chain, ok := req.Context().Value(redirectChainKey{}).(*[]string); ok {
+			*chain = append(*chain, req.URL.String())
+		}
+
+		if len(via) > maxRedirects {
+			return fmt.Errorf("больше %d редиректов", maxRedirects)
+		}
+		page, ok := req.Context().Value(redirectPageKey{}).(bool)
+		allowed := c.scope.Allow(req.URL, true)
+		if c.opts.PageRequisites && ok && !page {
+			allowed = c.scope.AllowRequisite(req.URL)
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s", errRedirectOutOfScope, req.URL)
+		}
+		return nil
+	}
+}
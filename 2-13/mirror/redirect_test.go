@@ -0,0 +1,116 @@
+package mirror
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// redirectTo ответ тестового сайта с редиректом
+func redirectTo(status int, location string) testPage {
+	return testPage{Status: status, Header: http.Header{"Location": {location}}}
+}
+
+func TestRedirectStoredUnderFinalURL(t *testing.T) {
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/":          {Body: `<a href="old">old</a> <a href="also">also</a>`},
+		"/old":       redirectTo(http.StatusFound, "/mid"),
+		"/mid":       redirectTo(http.StatusMovedPermanently, "/new.html"),
+		"/also":      redirectTo(http.StatusFound, "/new.html"),
+		"/new.html":  {Body: `new`},
+		"/never.png": {Body: `PNG`},
+	})
+
+	c, storage := runCrawl(t, Options{URL: srv.URL + "/", MaxDepth: 2, Workers: 1, ConvertLinks: true})
+
+	host := hostPrefix(srv)
+	want := []string{host + "/index.html", host + "/new.html"}
+	if got := storage.Names(); !slices.Equal(got, want) {
+		t.Errorf("saved %v, want %v", got, want)
+	}
+	old := c.state.Get(srv.URL + "/old")
+	if old == nil || old.RedirectTo != srv.URL+"/new.html" {
+		t.Fatalf("state of /old: %+v", old)
+	}
+	if chain := []string{srv.URL + "/mid", srv.URL + "/new.html"}; !slices.Equal(old.Redirects, chain) {
+		t.Errorf("redirect chain %v, want %v", old.Redirects, chain)
+	}
+	if p, ok := c.paths.Lookup(srv.URL + "/also"); !ok || p != host+"/new.html" {
+		t.Errorf("Lookup(/also) = %q, %v", p, ok)
+	}
+	// Ссылки на исходные адреса ведут на файл конечного
+	if got := fileString(t, storage, host+"/index.html"); got != `<a href="new.html">old</a> <a href="new.html">also</a>` {
+		t.Errorf("converted page %q", got)
+	}
+}
+
+func TestRedirectOutOfScope(t *testing.T) {
+	other, otherLog := newTestSite(t, map[string]testPage{
+		"/page.html": {Body: "other"},
+		"/a.png":     {Body: "PNG"},
+	})
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/":        {Body: `<a href="away">away</a> <img src="cdn.png">`},
+		"/away":    redirectTo(http.StatusFound, otherURL+"/page.html"),
+		"/cdn.png": redirectTo(http.StatusFound, otherURL+"/a.png"),
+	})
+
+	// Переход за пределы области отклоняется
+	c, _ := runCrawl(t, Options{URL: srv.URL + "/", MaxDepth: 1})
+	if got := otherLog.paths(); len(got) != 0 {
+		t.Errorf("other host requested %v", got)
+	}
+	if c.Failures().Len() != 2 {
+		t.Fatalf("%d failures", c.Failures().Len())
+	}
+	for _, f := range c.failures.items {
+		if !f.Permanent || !strings.Contains(f.Reason, errRedirectOutOfScope.Error()) {
+			t.Errorf("failure %+v", f)
+		}
+	}
+
+	// В режиме PageRequisites ресурс страницы может уйти на другой хост
+	c, storage := runCrawl(t, Options{URL: srv.URL + "/", PageRequisites: true})
+	if got := otherLog.paths(); !slices.Equal(got, []string{"/a.png"}) {
+		t.Errorf("other host requested %v", got)
+	}
+	// Ресурс сохраняется в каталоге хоста, на который ушёл редирект
+	otherHost := strings.Replace(hostPrefix(other), "127.0.0.1", "localhost", 1)
+	if !storage.Exists(otherHost + "/a.png") {
+		t.Errorf("cdn image not saved: %v", storage.Names())
+	}
+	if n := c.Failures().Len(); n != 0 {
+		t.Errorf("%d failures", n)
+	}
+}
+
+func TestRedirectLimit(t *testing.T) {
+	pages := map[string]testPage{"/": {Body: `<a href="r/0">loop</a> <a href="s/0">short</a>`}}
+	for i := 0; i < 2*maxRedirects; i++ {
+		pages[fmt.Sprintf("/r/%d", i)] = redirectTo(http.StatusFound, fmt.Sprintf("/r/%d", i+1))
+	}
+	for i := 0; i < maxRedirects; i++ {
+		pages[fmt.Sprintf("/s/%d", i)] = redirectTo(http.StatusFound, fmt.Sprintf("/s/%d", i+1))
+	}
+	pages[fmt.Sprintf("/s/%d", maxRedirects)] = testPage{Body: "end"}
+	srv, log := newTestSite(t, pages)
+
+	c, storage := runCrawl(t, Options{URL: srv.URL + "/", MaxDepth: 1})
+
+	// Ровно maxRedirects переходов допустимо, следующий — ошибка
+	if !storage.Exists(hostPrefix(srv) + fmt.Sprintf("/s/%d.html", maxRedirects)) {
+		t.Errorf("chain of %d redirects not followed: %v", maxRedirects, storage.Names())
+	}
+	if log.find(fmt.Sprintf("/r/%d", maxRedirects+1)) != nil {
+		t.Error("followed more than maxRedirects redirects")
+	}
+	if c.Failures().Len() != 1 {
+		t.Fatalf("%d failures", c.Failures().Len())
+	}
+	if f := c.failures.items[0]; f.URL != srv.URL+"/r/0" || !strings.Contains(f.Reason, fmt.Sprintf("больше %d редиректов", maxRedirects)) {
+		t.Errorf("failure %+v", f)
+	}
+}
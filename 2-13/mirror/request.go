@@ -0,0 +1,34 @@
+package mirror
+
+import (
+	"net/http"
+	"strings"
+)
+
+// newRequest создает запрос с User-Agent, пользовательскими заголовками
+// и Basic-авторизацией. Учётные данные отправляются только на стартовый
+// хост, чтобы не раскрывать их сторонним сайтам при SpanHosts.
+func (c *Crawler) newRequest(method, urlStr string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(c.ctx, method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	for name, values := range c.opts.Headers {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	// Заголовок Host задаётся через поле запроса, а не через Header
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+
+	if c.opts.AuthUser != "" && strings.EqualFold(req.URL.Hostname(), c.scope.StartURL.Hostname()) {
+		req.SetBasicAuth(c.opts.AuthUser, c.opts.AuthPassword)
+	}
+	return req, nil
+}
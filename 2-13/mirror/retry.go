@@ -0,0 +1,202 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// retryBaseDelay начальная задержка перед повтором
+const retryBaseDelay = time.Second
+
+// httpError ошибка, вызванная кодом ответа сервера
+type httpError struct {
+	StatusCode int
+	RetryAfter time.Duration // из заголовка Retry-After, 0 если его нет
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("HTTP статус: %d", e.StatusCode)
+}
+
+// newHTTPError создает ошибку по ответу сервера
+func newHTTPError(resp *http.Response) *httpError {
+	return &httpError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter разбирает Retry-After в секундах или в виде HTTP-даты
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// isRetryable сообщает, имеет ли смысл повторить запрос после ошибки.
+// 404 и 410 и прочие клиентские ошибки считаются окончательными.
+func isRetryable(err error) bool {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+
+	if errors.Is(err, errFileTooLarge) || errors.Is(err, errQuotaExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// retryDelay вычисляет паузу перед попыткой attempt (с 1):
+// экспоненциальный рост с полным джиттером, не больше WaitRetry.
+// Retry-After сервера имеет приоритет, но тоже ограничен WaitRetry:
+// иначе "Retry-After: 86400" занял бы воркер на сутки. Ожидание по
+// Retry-After расходует попытку, как и любой другой повтор.
+func (c *Crawler) retryDelay(attempt int, err error) time.Duration {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return min(httpErr.RetryAfter, c.opts.WaitRetry)
+	}
+
+	limit := retryBaseDelay << uint(attempt-1)
+	if limit <= 0 || limit > c.opts.WaitRetry {
+		limit = c.opts.WaitRetry
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)) + 1)
+}
+
+// sleep ждёт d или отмены обхода; false означает отмену
+func (c *Crawler) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// fetchWithRetry скачивает ресурс задачи, повторяя попытки при временных ошибках
+func (c *Crawler) fetchWithRetry(task DownloadTask, prev *ResourceState) (*fetchResult, error) {
+	urlStr := task.URL
+	tries := c.opts.Tries
+	if tries < 1 {
+		tries = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= tries; attempt++ {
+		var res *fetchResult
+		res, err = c.downloadResource(task, prev)
+		if err == nil {
+			return res, nil
+		}
+		// Обход отменён: задача останется во фронтире и не считается неудачной
+		if c.ctx.Err() != nil {
+			return nil, err
+		}
+		if !isRetryable(err) {
+			c.failures.Add(urlStr, err, attempt, true)
+			return nil, err
+		}
+		if attempt < tries {
+			delay := c.retryDelay(attempt, err)
+			c.progress.Logf("Повтор %d/%d для %s через %v: %v", attempt+1, tries, urlStr, delay.Round(time.Millisecond), err)
+			if !c.sleep(delay) {
+				return nil, c.ctx.Err()
+			}
+		}
+	}
+
+	c.failures.Add(urlStr, err, tries, false)
+	return nil, err
+}
+
+// Failure сведения о ресурсе, который не удалось скачать
+type Failure struct {
+	URL       string
+	Reason    string
+	Attempts  int
+	Permanent bool
+}
+
+// FailureReport накапливает неудачные загрузки за запуск
+type FailureReport struct {
+	mu    sync.Mutex
+	items []Failure
+}
+
+// Add записывает неудачную загрузку
+func (r *FailureReport) Add(urlStr string, err error, attempts int, permanent bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, Failure{
+		URL:       urlStr,
+		Reason:    err.Error(),
+		Attempts:  attempts,
+		Permanent: permanent,
+	})
+}
+
+// Len возвращает число неудачных загрузок
+func (r *FailureReport) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.items)
+}
+
+// WriteFile сохраняет отчёт в виде строк "URL<TAB>попыток<TAB>тип<TAB>причина"
+func (r *FailureReport) WriteFile(path string) error {
+	r.mu.Lock()
+	items := append([]Failure(nil), r.items...)
+	r.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].URL < items[j].URL })
+
+	var b strings.Builder
+	for _, f := range items {
+		kind := "temporary"
+		if f.Permanent {
+			kind = "permanent"
+		}
+		fmt.Fprintf(&b, "%s\t%d\t%s\t%s\n", f.URL, f.Attempts, kind, f.Reason)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,135 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryTransientFailures(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<img src="flaky.png"><img src="down.png"><img src="gone.png">`))
+		case "/flaky.png":
+			// Первая попытка — 503 с Retry-After на сутки
+			if hits.Add(1) == 1 {
+				w.Header().Set("Retry-After", "86400")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("PNG"))
+		case "/down.png":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	started := time.Now()
+	c, storage := runCrawl(t, Options{
+		URL:       srv.URL + "/",
+		MaxDepth:  1,
+		Tries:     3,
+		WaitRetry: 20 * time.Millisecond,
+	})
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("crawl took %v: Retry-After not capped by WaitRetry", elapsed)
+	}
+	if !storage.Exists(hostPrefix(srv) + "/flaky.png") {
+		t.Error("flaky.png not saved after a retry")
+	}
+
+	report := filepath.Join(t.TempDir(), "failed.txt")
+	if err := c.Failures().WriteFile(report); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%s/down.png\t3\ttemporary\tHTTP статус: 502\n%s/gone.png\t1\tpermanent\tHTTP статус: 404\n", srv.URL, srv.URL)
+	if string(data) != want {
+		t.Errorf("report:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&httpError{StatusCode: 500}, true},
+		{&httpError{StatusCode: 503}, true},
+		{&httpError{StatusCode: 429}, true},
+		{&httpError{StatusCode: 404}, false},
+		{&httpError{StatusCode: 410}, false},
+		{&httpError{StatusCode: 403}, false},
+		{fmt.Errorf("get: %w", syscall.ECONNRESET), true},
+		{fmt.Errorf("get: %w", syscall.ECONNREFUSED), true},
+		{io.ErrUnexpectedEOF, true},
+		{context.DeadlineExceeded, true},
+		{errFileTooLarge, false},
+		{errQuotaExceeded, false},
+		{errRedirectOutOfScope, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	c := &Crawler{opts: Options{WaitRetry: 4 * time.Second}}
+	for attempt := 1; attempt <= 10; attempt++ {
+		limit := min(retryBaseDelay<<(attempt-1), c.opts.WaitRetry)
+		if d := c.retryDelay(attempt, io.EOF); d <= 0 || d > limit {
+			t.Errorf("attempt %d: delay %v, want (0, %v]", attempt, d, limit)
+		}
+	}
+
+	tests := []struct {
+		retryAfter, want time.Duration
+	}{
+		{time.Second, time.Second},
+		{time.Hour, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		err := &httpError{StatusCode: 503, RetryAfter: tt.retryAfter}
+		if d := c.retryDelay(1, err); d != tt.want {
+			t.Errorf("Retry-After %v: delay %v, want %v", tt.retryAfter, d, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("120"); d != 2*time.Minute {
+		t.Errorf("seconds: %v", d)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("date %s: %v", date, d)
+	}
+	for _, v := range []string{"", "0", "-5", "soon", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)} {
+		if d := parseRetryAfter(v); d != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", v, d)
+		}
+	}
+	if !strings.Contains((&httpError{StatusCode: 418}).Error(), "418") {
+		t.Error("status missing from the error text")
+	}
+}
@@ -0,0 +1,267 @@
+package mirror
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler очередь задач, сгруппированная по хостам. Next выдаёт задачу
+// только от хоста, у которого не исчерпан лимит одновременных загрузок
+// и прошла пауза Wait после предыдущего запроса.
+//
+// Обход закончен, когда очередь пуста и ни одна задача не в работе:
+// новые задачи добавляются только обработчиками текущих, поэтому
+// начальные задачи нужно поставить до запуска воркеров.
+type Scheduler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	perHost    int           // 0 — без ограничения
+	wait       time.Duration // пауза между запросами к одному хосту
+	randomWait bool          // пауза случайна в пределах 0.5–1.5 × wait
+
+	queues  map[string][]DownloadTask
+	hosts   []string // хосты с задачами в порядке обхода по кругу
+	next    int
+	active  map[string]int
+	running int // задач в работе по всем хостам
+	readyAt map[string]time.Time
+	closed  bool
+
+	// Таймер, который будит ожидающих, когда хост освободится после паузы;
+	// один на все ожидания до одного и того же момента
+	wake   *time.Timer
+	wakeAt time.Time
+}
+
+// NewScheduler создает планировщик
+func NewScheduler(perHost int, wait time.Duration, randomWait bool) *Scheduler {
+	s := &Scheduler{
+		perHost:    perHost,
+		wait:       wait,
+		randomWait: randomWait,
+		queues:     make(map[string][]DownloadTask),
+		active:     make(map[string]int),
+		readyAt:    make(map[string]time.Time),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Push добавляет задачу в очередь её хоста. Никогда не блокируется.
+func (s *Scheduler) Push(task DownloadTask) {
+	host := taskHost(task.URL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queues[host]) == 0 {
+		s.hosts = append(s.hosts, host)
+	}
+	s.queues[host] = append(s.queues[host], task)
+	s.cond.Signal()
+}
+
+// Next ждёт задачу от ненасыщенного хоста. Возвращает false, когда
+// обход закончен или вызван Close.
+func (s *Scheduler) Next() (DownloadTask, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.closed || (len(s.hosts) == 0 && s.running == 0) {
+			return DownloadTask{}, false
+		}
+
+		task, wakeAt, ok := s.pick(time.Now())
+		if ok {
+			return task, true
+		}
+		// Хост освободится по таймеру паузы — будим ожидающих в этот момент
+		if !wakeAt.IsZero() {
+			s.setWake(wakeAt)
+		}
+		s.cond.Wait()
+	}
+}
+
+// setWake заводит таймер пробуждения на момент at. Таймер на тот же момент
+// уже заведён другим ожидающим, таймер на другой момент останавливается.
+func (s *Scheduler) setWake(at time.Time) {
+	if s.wake != nil {
+		if s.wakeAt.Equal(at) {
+			return
+		}
+		s.wake.Stop()
+	}
+	s.wake = time.AfterFunc(time.Until(at), s.cond.Broadcast)
+	s.wakeAt = at
+}
+
+// pick выбирает задачу по кругу среди хостов. Если все хосты с задачами
+// ждут паузу, возвращает ближайший момент, когда один из них освободится.
+func (s *Scheduler) pick(now time.Time) (DownloadTask, time.Time, bool) {
+	var wakeAt time.Time
+	for i := 0; i < len(s.hosts); i++ {
+		idx := (s.next + i) % len(s.hosts)
+		host := s.hosts[idx]
+
+		if s.perHost > 0 && s.active[host] >= s.perHost {
+			continue
+		}
+		if ready := s.readyAt[host]; ready.After(now) {
+			if wakeAt.IsZero() || ready.Before(wakeAt) {
+				wakeAt = ready
+			}
+			continue
+		}
+
+		queue := s.queues[host]
+		task := queue[0]
+		if len(queue) == 1 {
+			delete(s.queues, host)
+			s.hosts = append(s.hosts[:idx], s.hosts[idx+1:]...)
+			s.next = idx
+		} else {
+			s.queues[host] = queue[1:]
+			s.next = idx + 1
+		}
+		if len(s.hosts) > 0 {
+			s.next %= len(s.hosts)
+		} else {
+			s.next = 0
+		}
+
+		s.active[host]++
+		s.running++
+		if d := s.delay(); d > 0 {
+			s.readyAt[host] = now.Add(d)
+		}
+		return task, time.Time{}, true
+	}
+	return DownloadTask{}, wakeAt, false
+}
+
+// delay возвращает паузу перед следующим запросом к тому же хосту
+func (s *Scheduler) delay() time.Duration {
+	if s.wait <= 0 || !s.randomWait {
+		return s.wait
+	}
+	return s.wait/2 + time.Duration(rand.Int63n(int64(s.wait)+1))
+}
+
+// Done освобождает место хоста после обработки задачи
+func (s *Scheduler) Done(task DownloadTask) {
+	host := taskHost(task.URL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[host]--; s.active[host] <= 0 {
+		delete(s.active, host)
+	}
+	s.running--
+	s.cond.Broadcast()
+}
+
+// Close прекращает выдачу задач и будит ожидающих воркеров
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.wake != nil {
+		s.wake.Stop()
+	}
+	s.cond.Broadcast()
+}
+
+// taskHost ключ очереди: хост с портом в нижнем регистре
+func taskHost(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// RateLimiter ограничивает суммарную скорость чтения всех загрузок
+type RateLimiter struct {
+	mu   sync.Mutex
+	rate int64 // байт в секунду
+	next time.Time
+}
+
+// NewRateLimiter создает ограничитель; при rate <= 0 возвращает nil,
+// и Reader отдаёт поток без ограничения
+func NewRateLimiter(rate int64) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &RateLimiter{rate: rate}
+}
+
+// Reader оборачивает поток ограничителем скорости. Ожидание прерывается
+// отменой ctx, и чтение возвращает её ошибку.
+func (l *RateLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+// wait резервирует время на передачу n байт и ждёт его наступления
+// или отмены ctx
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunk размер одного чтения: примерно десятая доля секунды трафика,
+// чтобы скорость выравнивалась плавно, а не рывками по 32 КБ
+func (l *RateLimiter) chunk() int {
+	if c := l.rate / 10; c > 512 {
+		return int(c)
+	}
+	return 512
+}
+
+// limitedReader поток, читающий не быстрее общего лимита
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if c := r.limiter.chunk(); len(p) > c {
+		p = p[:c]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
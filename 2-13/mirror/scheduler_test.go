@@ -0,0 +1,161 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRoundRobin(t *testing.T) {
+	s := NewScheduler(0, 0, false)
+	for _, u := range []string{"http://a/1", "http://a/2", "http://b/1", "http://A/3", "http://c/1"} {
+		s.Push(DownloadTask{URL: u})
+	}
+	var got []string
+	for i := 0; i < 5; i++ {
+		task, ok := s.Next()
+		if !ok {
+			t.Fatalf("Next %d: queue closed", i)
+		}
+		got = append(got, task.URL)
+		s.Done(task)
+	}
+	// Хосты чередуются, регистр имени хоста не различается
+	if want := "http://a/1 http://b/1 http://c/1 http://a/2 http://A/3"; strings.Join(got, " ") != want {
+		t.Errorf("order %v, want %s", got, want)
+	}
+	if _, ok := s.Next(); ok {
+		t.Error("Next returned a task from an empty queue")
+	}
+}
+
+func TestSchedulerPerHostLimit(t *testing.T) {
+	s := NewScheduler(1, 0, false)
+	s.Push(DownloadTask{URL: "http://a/1"})
+	s.Push(DownloadTask{URL: "http://a/2"})
+	s.Push(DownloadTask{URL: "http://b/1"})
+
+	first, _ := s.Next()
+	second, _ := s.Next()
+	if first.URL != "http://a/1" || second.URL != "http://b/1" {
+		t.Fatalf("got %s, %s", first.URL, second.URL)
+	}
+
+	// Второй запрос к хосту a ждёт завершения первого
+	next := make(chan DownloadTask)
+	go func() {
+		task, _ := s.Next()
+		next <- task
+	}()
+	select {
+	case task := <-next:
+		t.Fatalf("got %s while a is busy", task.URL)
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.Done(first)
+	if task := <-next; task.URL != "http://a/2" {
+		t.Errorf("got %s", task.URL)
+	}
+}
+
+func TestSchedulerWaitReusesTimer(t *testing.T) {
+	const wait = 200 * time.Millisecond
+	s := NewScheduler(0, wait, false)
+	s.Push(DownloadTask{URL: "http://a/1"})
+	first, _ := s.Next()
+	started := time.Now()
+	s.Done(first)
+	s.Push(DownloadTask{URL: "http://a/2"})
+
+	next := make(chan DownloadTask)
+	go func() {
+		task, _ := s.Next()
+		next <- task
+	}()
+
+	// Лишние пробуждения до конца паузы не заводят новых таймеров
+	time.Sleep(20 * time.Millisecond)
+	s.mu.Lock()
+	timer := s.wake
+	s.mu.Unlock()
+	if timer == nil {
+		t.Fatal("no wake timer while the host waits")
+	}
+	for i := 0; i < 5; i++ {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.mu.Lock()
+	if s.wake != timer {
+		t.Error("wake timer replaced for the same wake time")
+	}
+	s.mu.Unlock()
+
+	task := <-next
+	if elapsed := time.Since(started); task.URL != "http://a/2" || elapsed < wait {
+		t.Errorf("got %s after %v, want a/2 after %v", task.URL, elapsed, wait)
+	}
+}
+
+func TestSchedulerClose(t *testing.T) {
+	s := NewScheduler(0, time.Hour, false)
+	s.Push(DownloadTask{URL: "http://a/1"})
+	s.Push(DownloadTask{URL: "http://a/2"})
+	first, _ := s.Next()
+
+	done := make(chan bool)
+	go func() {
+		_, ok := s.Next()
+		done <- ok
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Next returned a task after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not wake the waiting worker")
+	}
+	s.Done(first)
+}
+
+func TestRateLimiter(t *testing.T) {
+	if r := NewRateLimiter(0).Reader(context.Background(), strings.NewReader("x")); r == nil {
+		t.Fatal("nil reader without a limit")
+	}
+
+	// 8 КБ при 40 КБ/с — около 0.2 с
+	l := NewRateLimiter(40 << 10)
+	started := time.Now()
+	n, err := io.Copy(io.Discard, l.Reader(context.Background(), strings.NewReader(strings.Repeat("x", 8<<10))))
+	elapsed := time.Since(started)
+	if err != nil || n != 8<<10 {
+		t.Fatalf("copied %d: %v", n, err)
+	}
+	if elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("8 KB at 40 KB/s took %v", elapsed)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	// 1 КБ/с: чтение 10 КБ без отмены заняло бы 10 секунд
+	l := NewRateLimiter(1 << 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := io.Copy(io.Discard, l.Reader(ctx, strings.NewReader(strings.Repeat("x", 10<<10))))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("cancelled read took %v", elapsed)
+	}
+}
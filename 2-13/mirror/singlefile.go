@@ -0,0 +1,291 @@
+package mirror
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SingleFileFormat формат выгрузки страницы в один файл
+type SingleFileFormat string
+
+const (
+	// SingleFileMHTML multipart/related, части находятся по Content-Location
+	SingleFileMHTML SingleFileFormat = "mhtml"
+	// SingleFileHTML HTML, в котором ресурсы встроены как data: URI
+	SingleFileHTML SingleFileFormat = "html"
+)
+
+// WriteSingleFile собирает стартовую страницу и скачанные для неё ресурсы
+// в один файл. Вызывается после Run, обычно вместе с PageRequisites.
+func (c *Crawler) WriteSingleFile(w io.Writer, format SingleFileFormat) error {
+	page := c.resource(c.opts.URL)
+	if page == nil || !isHTMLContent(page.ContentType) {
+		return errors.New("стартовая страница не скачана")
+	}
+
+	switch format {
+	case SingleFileMHTML:
+		return c.writeMHTML(w, page)
+	case SingleFileHTML:
+		return c.writeInlineHTML(w, page)
+	default:
+		return fmt.Errorf("неизвестный формат %q", format)
+	}
+}
+
+// resource возвращает сведения о скачанном ресурсе с учётом редиректа
+// или nil, если файла нет в хранилище
+func (c *Crawler) resource(urlStr string) *ResourceState {
+	res := c.state.Get(urlStr)
+	if res != nil && res.RedirectTo != "" {
+		res = c.state.Get(res.RedirectTo)
+	}
+	if res == nil || !c.storage.Exists(res.LocalPath) {
+		return nil
+	}
+	return res
+}
+
+// readResource читает файл ресурса из хранилища
+func (c *Crawler) readResource(res *ResourceState) ([]byte, error) {
+	f, err := c.storage.Open(res.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// requisites возвращает страницу и все скачанные ресурсы, нужные для её
+// отображения: ресурсы страницы, таблиц стилей и фреймов, без переходов по <a>
+func (c *Crawler) requisites(page *ResourceState) []*ResourceState {
+	result := []*ResourceState{page}
+	seen := map[string]bool{page.URL: true}
+	for i := 0; i < len(result); i++ {
+		for _, urlStr := range result[i].Resources {
+			res := c.resource(urlStr)
+			if res == nil || seen[res.URL] {
+				continue
+			}
+			seen[res.URL] = true
+			result = append(result, res)
+		}
+	}
+	return result
+}
+
+// linkResolver возвращает функцию, которая разрешает ссылку документа
+// baseURL, сохранённого в localPath, и находит скачанный ресурс. Ссылки,
+// переписанные ConvertLinks на локальные файлы, переводятся обратно в URL,
+// как в linkTarget. Для нескачанных ресурсов res == nil, target —
+// абсолютный URL; fragment нужно дописать к итоговой ссылке.
+func (c *Crawler) linkResolver(baseURL *url.URL, localPath string) func(href string) (res *ResourceState, target, fragment string) {
+	return func(href string) (*ResourceState, string, string) {
+		resolved := c.linkTarget(href, baseURL, localPath)
+		if resolved == nil {
+			return nil, href, ""
+		}
+		fragment := ""
+		if resolved.Fragment != "" {
+			fragment = "#" + resolved.Fragment
+			resolved.Fragment = ""
+		}
+		return c.resource(resolved.String()), resolved.String(), fragment
+	}
+}
+
+// writeMHTML пишет страницу в формате MHTML. Ссылки в HTML и CSS
+// переписываются на конечные абсолютные адреса, чтобы совпадать
+// с Content-Location частей.
+func (c *Crawler) writeMHTML(w io.Writer, page *ResourceState) error {
+	bw := bufio.NewWriter(w)
+	mw := multipart.NewWriter(bw)
+
+	fmt.Fprintf(bw, "From: <Saved by %s>\r\n", c.opts.UserAgent)
+	fmt.Fprintf(bw, "Snapshot-Content-Location: %s\r\n", page.URL)
+	fmt.Fprintf(bw, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(bw, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(bw, "Content-Type: multipart/related; type=\"text/html\"; boundary=\"%s\"\r\n\r\n", mw.Boundary())
+
+	for _, res := range c.requisites(page) {
+		content, err := c.readResource(res)
+		if err != nil {
+			return err
+		}
+		if isHTMLContent(res.ContentType) || isCSSContent(res.ContentType) {
+			base, err := url.Parse(res.URL)
+			if err != nil {
+				return err
+			}
+			resolve := c.linkResolver(base, res.LocalPath)
+			content, err = rewriteResource(content, res.ContentType, func(href string, _ bool) string {
+				dep, target, fragment := resolve(href)
+				if dep != nil {
+					return dep.URL + fragment
+				}
+				return target + fragment
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", res.URL, err)
+			}
+		}
+		if err := writeMIMEPart(mw, res, content); err != nil {
+			return err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeMIMEPart пишет одну часть MHTML: текст в quoted-printable,
+// остальное в base64
+func writeMIMEPart(mw *multipart.Writer, res *ResourceState, content []byte) error {
+	contentType := res.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	text := strings.HasPrefix(strings.ToLower(contentType), "text/")
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Location", res.URL)
+	if text {
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+	} else {
+		header.Set("Content-Transfer-Encoding", "base64")
+	}
+
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if text {
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write(content); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+
+	// base64 строками по 76 символов, как требует MIME
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := io.WriteString(part, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// writeInlineHTML пишет страницу, в которой скачанные ресурсы заменены
+// data: URI, а остальные ссылки — абсолютными адресами
+func (c *Crawler) writeInlineHTML(w io.Writer, page *ResourceState) error {
+	inliner := &inliner{crawler: c, cache: make(map[string]string), active: make(map[string]bool)}
+	content, err := inliner.inline(page)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// inliner встраивает ресурсы в HTML и CSS рекурсивно: таблица стилей
+// встраивается вместе со своими картинками и шрифтами, фрейм — со своими ресурсами
+type inliner struct {
+	crawler *Crawler
+	cache   map[string]string // готовые data: URI по URL ресурса
+	active  map[string]bool   // документы в процессе встраивания, защита от циклов
+}
+
+// inline возвращает содержимое ресурса; в HTML и CSS ссылки на скачанные
+// ресурсы заменены data: URI
+func (in *inliner) inline(res *ResourceState) ([]byte, error) {
+	content, err := in.crawler.readResource(res)
+	if err != nil {
+		return nil, err
+	}
+	if !isHTMLContent(res.ContentType) && !isCSSContent(res.ContentType) {
+		return content, nil
+	}
+
+	base, err := url.Parse(res.URL)
+	if err != nil {
+		return nil, err
+	}
+	resolve := in.crawler.linkResolver(base, res.LocalPath)
+
+	in.active[res.URL] = true
+	defer delete(in.active, res.URL)
+
+	var inlineErr error
+	updated, err := rewriteResource(content, res.ContentType, func(href string, resource bool) string {
+		dep, target, fragment := resolve(href)
+		// Переходы по <a> и циклические ссылки остаются адресами
+		if dep == nil || !resource || in.active[dep.URL] {
+			return target + fragment
+		}
+		uri, err := in.dataURI(dep)
+		if err != nil {
+			if inlineErr == nil {
+				inlineErr = fmt.Errorf("%s: %w", dep.URL, err)
+			}
+			return target + fragment
+		}
+		return uri + fragment
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", res.URL, err)
+	}
+	return updated, inlineErr
+}
+
+// dataURI возвращает ресурс в виде data: URI
+func (in *inliner) dataURI(res *ResourceState) (string, error) {
+	if uri, ok := in.cache[res.URL]; ok {
+		return uri, nil
+	}
+	content, err := in.inline(res)
+	if err != nil {
+		return "", err
+	}
+	uri := "data:" + dataMediaType(res.ContentType) + ";base64," + base64.StdEncoding.EncodeToString(content)
+	in.cache[res.URL] = uri
+	return uri, nil
+}
+
+// dataMediaType записывает Content-Type без пробелов, как принято в data: URI
+func dataMediaType(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(mediaType)
+	for _, k := range keys {
+		b.WriteString(";" + k + "=" + url.PathEscape(params[k]))
+	}
+	return b.String()
+}
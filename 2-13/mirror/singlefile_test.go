@@ -0,0 +1,150 @@
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// singleFileSite сайт для выгрузки в один файл: страница с таблицей стилей,
+// картинками (одна на другом хосте) и ссылкой на нескачиваемую страницу
+func singleFileSite(t *testing.T) (pageURL, otherURL string) {
+	t.Helper()
+	other, _ := newTestSite(t, map[string]testPage{
+		"/logo.png": {Body: "PNG-LOGO"},
+	})
+	otherURL = strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/docs/": {Body: `<link rel="stylesheet" href="css/s.css"><img src="img/a.png">` +
+			`<img src="` + otherURL + `/logo.png"><a href="page.html#part">next</a>`},
+		"/docs/css/s.css":  {Body: `body { background: url(../img/bg.png) }`},
+		"/docs/img/a.png":  {Body: "PNG-A"},
+		"/docs/img/bg.png": {Body: "PNG-BG"},
+		"/docs/page.html":  {Body: "page"},
+	})
+	return srv.URL + "/docs/", otherURL
+}
+
+// readMHTML возвращает части MHTML по Content-Location
+func readMHTML(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[string]string)
+	mr := multipart.NewReader(r.R, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// quoted-printable multipart.Reader раскодирует сам
+		var body []byte
+		if part.Header.Get("Content-Transfer-Encoding") == "base64" {
+			body, err = io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		} else {
+			body, err = io.ReadAll(part)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[part.Header.Get("Content-Location")] = string(body)
+	}
+}
+
+func TestWriteSingleFileMHTML(t *testing.T) {
+	// С ConvertLinks ссылки в сохранённых файлах локальные и должны
+	// переводиться обратно в адреса
+	for _, convert := range []bool{false, true} {
+		pageURL, otherURL := singleFileSite(t)
+		c, _ := runCrawl(t, Options{URL: pageURL, PageRequisites: true, ConvertLinks: convert})
+
+		var out bytes.Buffer
+		if err := c.WriteSingleFile(&out, SingleFileMHTML); err != nil {
+			t.Fatalf("convert %v: %v", convert, err)
+		}
+		parts := readMHTML(t, out.Bytes())
+
+		want := map[string]string{
+			pageURL + "css/s.css":  `body { background: url(` + pageURL + `img/bg.png) }`,
+			pageURL + "img/a.png":  "PNG-A",
+			pageURL + "img/bg.png": "PNG-BG",
+			otherURL + "/logo.png": "PNG-LOGO",
+			pageURL: `<link rel="stylesheet" href="` + pageURL + `css/s.css"><img src="` + pageURL + `img/a.png">` +
+				`<img src="` + otherURL + `/logo.png"><a href="` + pageURL + `page.html#part">next</a>`,
+		}
+		if len(parts) != len(want) {
+			t.Errorf("convert %v: parts %v", convert, mapKeys(parts))
+		}
+		for location, body := range want {
+			if parts[location] != body {
+				t.Errorf("convert %v: part %s = %q, want %q", convert, location, parts[location], body)
+			}
+		}
+	}
+}
+
+func TestWriteSingleFileHTML(t *testing.T) {
+	dataURI := regexp.MustCompile(`data:([^;,]+)(?:;[^;,]+)*;base64,([A-Za-z0-9+/=]+)`)
+	for _, convert := range []bool{false, true} {
+		pageURL, otherURL := singleFileSite(t)
+		c, _ := runCrawl(t, Options{URL: pageURL, PageRequisites: true, ConvertLinks: convert})
+
+		var out bytes.Buffer
+		if err := c.WriteSingleFile(&out, SingleFileHTML); err != nil {
+			t.Fatalf("convert %v: %v", convert, err)
+		}
+
+		// Встроенные ресурсы по порядку: таблица стилей, две картинки
+		var inlined []string
+		for _, m := range dataURI.FindAllStringSubmatch(out.String(), -1) {
+			data, err := base64.StdEncoding.DecodeString(m[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			inlined = append(inlined, m[1]+" "+string(data))
+		}
+		bg := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("PNG-BG"))
+		want := []string{
+			"text/css body { background: url(" + bg + ") }",
+			"image/png PNG-A",
+			"image/png PNG-LOGO",
+		}
+		if strings.Join(inlined, "\n") != strings.Join(want, "\n") {
+			t.Errorf("convert %v: inlined\n%s\nwant\n%s", convert, strings.Join(inlined, "\n"), strings.Join(want, "\n"))
+		}
+		// Переход остаётся абсолютной ссылкой
+		if !strings.Contains(out.String(), `<a href="`+pageURL+`page.html#part">`) {
+			t.Errorf("convert %v: page %s", convert, out.String())
+		}
+		if strings.Contains(out.String(), otherURL) {
+			t.Errorf("convert %v: other host image not inlined", convert)
+		}
+	}
+}
+
+// mapKeys ключи отображения для сообщений об ошибках
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,175 @@
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// maxSitemapSize предел размера sitemap после распаковки (по протоколу sitemaps.org)
+	maxSitemapSize = 50 << 20
+	// maxSitemapNesting предел вложенности sitemap index
+	maxSitemapNesting = 3
+)
+
+// sitemapDocument покрывает и <urlset>, и <sitemapindex>
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLoc элемент с адресом
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// seedFromSitemaps ставит в очередь страницы из /sitemap.xml и sitemap,
+// перечисленных в robots.txt. Страницы добавляются с глубиной 0.
+func (c *Crawler) seedFromSitemaps(startURL *url.URL) {
+	root := &url.URL{Scheme: startURL.Scheme, Host: startURL.Host}
+
+	sitemaps := []string{root.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()}
+	robots, err := c.robotsSitemaps(root.ResolveReference(&url.URL{Path: "/robots.txt"}).String())
+	if err != nil {
+		c.progress.Logf("Не удалось прочитать robots.txt: %v", err)
+	}
+	sitemaps = append(sitemaps, robots...)
+
+	seen := make(map[string]bool)
+	var pages []sitemapPage
+	for _, sm := range removeDuplicates(sitemaps) {
+		pages = append(pages, c.collectSitemap(sm, 0, seen)...)
+	}
+
+	added := 0
+	queued := make(map[string]bool)
+	for _, page := range pages {
+		if queued[page.url] || !c.shouldDownload(page.url, true) {
+			continue
+		}
+		queued[page.url] = true
+		// Ребро от sitemap, как от "" к стартовой странице в Run: иначе
+		// битая ссылка из sitemap не попадёт в отчёт Spider
+		if c.spider != nil {
+			c.spider.AddEdge(page.sitemap, page.url)
+		}
+		c.enqueue(DownloadTask{
+			URL:   page.url,
+			Depth: 0,
+			Type:  "html",
+		})
+		added++
+	}
+	c.progress.Logf("Из sitemap добавлено страниц: %d", added)
+}
+
+// sitemapPage страница из sitemap и sitemap, в котором она указана
+type sitemapPage struct {
+	url     string
+	sitemap string
+}
+
+// collectSitemap возвращает страницы из sitemap, рекурсивно обходя индексы
+func (c *Crawler) collectSitemap(sitemapURL string, nesting int, seen map[string]bool) []sitemapPage {
+	if seen[sitemapURL] || nesting > maxSitemapNesting {
+		return nil
+	}
+	seen[sitemapURL] = true
+
+	doc, err := c.fetchSitemap(sitemapURL)
+	if err != nil {
+		c.progress.Logf("Ошибка чтения sitemap %s: %v", sitemapURL, err)
+		return nil
+	}
+
+	base, _ := url.Parse(sitemapURL)
+	var pages []sitemapPage
+	for _, u := range doc.URLs {
+		if abs := resolveURL(strings.TrimSpace(u.Loc), base); abs != "" {
+			pages = append(pages, sitemapPage{url: abs, sitemap: sitemapURL})
+		}
+	}
+	for _, sm := range doc.Sitemaps {
+		if abs := resolveURL(strings.TrimSpace(sm.Loc), base); abs != "" {
+			pages = append(pages, c.collectSitemap(abs, nesting+1, seen)...)
+		}
+	}
+	return pages
+}
+
+// fetchSitemap скачивает и разбирает sitemap, распаковывая gzip при необходимости
+func (c *Crawler) fetchSitemap(sitemapURL string) (*sitemapDocument, error) {
+	body, err := c.fetchSmall(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сжатый sitemap.xml.gz определяем по сигнатуре, а не по имени
+	if len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body, err = io.ReadAll(io.LimitReader(zr, maxSitemapSize))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("неожиданный корневой элемент <%s>", doc.XMLName.Local)
+	}
+	return &doc, nil
+}
+
+// robotsSitemaps возвращает адреса из строк "Sitemap:" файла robots.txt
+func (c *Crawler) robotsSitemaps(robotsURL string) ([]string, error) {
+	body, err := c.fetchSmall(robotsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var sitemaps []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, ':'); i != -1 && strings.EqualFold(line[:i], "sitemap") {
+			if loc := strings.TrimSpace(line[i+1:]); loc != "" {
+				sitemaps = append(sitemaps, loc)
+			}
+		}
+	}
+	return sitemaps, scanner.Err()
+}
+
+// fetchSmall скачивает служебный файл целиком в память с ограничением размера
+func (c *Crawler) fetchSmall(urlStr string) ([]byte, error) {
+	req, err := c.newRequest("GET", urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSitemapSize))
+}
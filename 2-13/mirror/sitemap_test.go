@@ -0,0 +1,64 @@
+package mirror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"slices"
+	"testing"
+)
+
+func TestSitemapSeeds(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<?xml version="1.0"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>/maps/pages.xml</loc></sitemap>
+  <sitemap><loc>/maps/index.xml.gz</loc></sitemap>
+</sitemapindex>`))
+	zw.Close()
+
+	pages := map[string]testPage{
+		"/":                  {Body: `start`},
+		"/sitemap.xml":       {Type: "application/xml", Body: `<urlset><url><loc> /hidden.html </loc></url><url><loc>/</loc></url></urlset>`},
+		"/maps/index.xml.gz": {Type: "application/octet-stream", Body: gz.String()},
+		"/maps/pages.xml": {Type: "application/xml", Body: `<urlset>
+  <url><loc>orphan.html</loc></url>
+  <url><loc>http://other.invalid/page.html</loc></url>
+  <url><loc>/hidden.html</loc></url>
+</urlset>`},
+		"/hidden.html":      {Body: `hidden`},
+		"/maps/orphan.html": {Body: `orphan`},
+	}
+	srv, log := newTestSite(t, pages)
+	// В robots.txt адрес sitemap абсолютный
+	pages["/robots.txt"] = testPage{Type: "text/plain", Body: "User-agent: *\nDisallow:\nSITEMAP: " + srv.URL + "/maps/index.xml.gz\n"}
+
+	_, storage := runCrawl(t, Options{URL: srv.URL + "/", Sitemap: true})
+
+	host := hostPrefix(srv)
+	want := []string{host + "/hidden.html", host + "/index.html", host + "/maps/orphan.html"}
+	if got := storage.Names(); !slices.Equal(got, want) {
+		t.Errorf("saved %v, want %v", got, want)
+	}
+	// Индекс, ссылающийся сам на себя, читается один раз
+	requests := log.paths()
+	if n := len(slices.DeleteFunc(requests, func(p string) bool { return p != "/maps/index.xml.gz" })); n != 1 {
+		t.Errorf("index fetched %d times", n)
+	}
+}
+
+func TestSitemapMissing(t *testing.T) {
+	srv, _ := newTestSite(t, map[string]testPage{
+		"/":            {Body: `start`},
+		"/sitemap.xml": {Body: `<html>not a sitemap</html>`},
+	})
+
+	c, storage := runCrawl(t, Options{URL: srv.URL + "/", Sitemap: true})
+	if got := storage.Names(); len(got) != 1 {
+		t.Errorf("saved %v", got)
+	}
+	// Ошибки sitemap и robots.txt не считаются ошибками обхода
+	if n := c.Failures().Len(); n != 0 {
+		t.Errorf("%d failures", n)
+	}
+}
@@ -0,0 +1,311 @@
+package mirror
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSpiderPageSize сколько байт HTML-страницы читается в режиме -spider для поиска ссылок
+const maxSpiderPageSize = 10 << 20
+
+// LinkEdge ребро графа ссылок с результатом проверки цели
+type LinkEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Status    int    `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Broken сообщает, что ссылка битая: ошибка сети или статус 4xx/5xx
+func (e LinkEdge) Broken() bool {
+	return e.Error != "" || e.Status >= 400
+}
+
+// linkCheck результат проверки одного URL
+type linkCheck struct {
+	status  int
+	latency time.Duration
+	err     string
+}
+
+// SpiderReport собирает рёбра и результаты проверок в режиме -spider
+type SpiderReport struct {
+	mu      sync.Mutex
+	edges   map[[2]string]bool
+	results map[string]linkCheck
+}
+
+// NewSpiderReport создает пустой отчёт
+func NewSpiderReport() *SpiderReport {
+	return &SpiderReport{
+		edges:   make(map[[2]string]bool),
+		results: make(map[string]linkCheck),
+	}
+}
+
+// AddEdge запоминает ссылку со страницы from на to
+func (r *SpiderReport) AddEdge(from, to string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.edges[[2]string{from, to}] = true
+}
+
+// SetResult запоминает результат проверки URL
+func (r *SpiderReport) SetResult(urlStr string, status int, latency time.Duration, err error) {
+	check := linkCheck{status: status, latency: latency}
+	if err != nil {
+		check.err = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[urlStr] = check
+}
+
+// Edges возвращает проверенные рёбра, отсортированные по странице и цели
+func (r *SpiderReport) Edges() []LinkEdge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	edges := make([]LinkEdge, 0, len(r.edges))
+	for e := range r.edges {
+		check, ok := r.results[e[1]]
+		if !ok {
+			continue // цель не проверялась: вне области обхода или глубины
+		}
+		edges = append(edges, LinkEdge{
+			From:      e[0],
+			To:        e[1],
+			Status:    check.status,
+			LatencyMs: check.latency.Milliseconds(),
+			Error:     check.err,
+		})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// Write выводит отчёт в формате text, json или junit и возвращает число битых ссылок
+func (r *SpiderReport) Write(w io.Writer, format string) (int, error) {
+	edges := r.Edges()
+	broken := 0
+	for _, e := range edges {
+		if e.Broken() {
+			broken++
+		}
+	}
+
+	var err error
+	switch strings.ToLower(format) {
+	case "text":
+		err = writeSpiderText(w, edges, broken)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Total  int        `json:"total"`
+			Broken int        `json:"broken"`
+			Edges  []LinkEdge `json:"edges"`
+		}{len(edges), broken, edges})
+	case "junit":
+		err = writeSpiderJUnit(w, edges, broken)
+	default:
+		err = fmt.Errorf("неизвестный формат отчёта %q", format)
+	}
+	return broken, err
+}
+
+// writeSpiderText выводит по строке на ссылку и итог
+func writeSpiderText(w io.Writer, edges []LinkEdge, broken int) error {
+	for _, e := range edges {
+		mark := "OK  "
+		if e.Broken() {
+			mark = "FAIL"
+		}
+		line := fmt.Sprintf("%s %3d %6dms %s -> %s", mark, e.Status, e.LatencyMs, edgeSource(e), e.To)
+		if e.Error != "" {
+			line += " (" + e.Error + ")"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "Проверено ссылок: %d, битых: %d\n", len(edges), broken)
+	return err
+}
+
+// edgeSource имя страницы-источника для вывода; у стартового URL её нет
+func edgeSource(e LinkEdge) string {
+	if e.From == "" {
+		return "(start)"
+	}
+	return e.From
+}
+
+// junitTestSuites корневой элемент отчёта JUnit XML
+type junitTestSuites struct {
+	XMLName xml.Name       `xml:"testsuites"`
+	Suites  []junitTestSet `xml:"testsuite"`
+}
+
+// junitTestSet набор тестов JUnit
+type junitTestSet struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase одна проверенная ссылка
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure описание битой ссылки
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeSpiderJUnit выводит отчёт JUnit XML: страница — classname, цель — name
+func writeSpiderJUnit(w io.Writer, edges []LinkEdge, broken int) error {
+	suite := junitTestSet{Name: "link-check", Tests: len(edges), Failures: broken}
+	var total int64
+	for _, e := range edges {
+		total += e.LatencyMs
+		tc := junitTestCase{
+			ClassName: edgeSource(e),
+			Name:      e.To,
+			Time:      fmt.Sprintf("%.3f", float64(e.LatencyMs)/1000),
+		}
+		if e.Broken() {
+			msg := fmt.Sprintf("HTTP %d", e.Status)
+			if e.Error != "" {
+				msg = e.Error
+			}
+			tc.Failure = &junitFailure{Message: msg, Text: fmt.Sprintf("%s -> %s: %s", edgeSource(e), e.To, msg)}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = fmt.Sprintf("%.3f", float64(total)/1000)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSet{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// spiderTask проверяет URL без сохранения: HEAD для ресурсов и страниц на
+// последней глубине, GET для страниц, ссылки которых нужно обойти
+func (c *Crawler) spiderTask(task DownloadTask) {
+	crawl := task.Type == "html" && task.Depth < c.opts.MaxDepth
+	method := "HEAD"
+	if crawl {
+		method = "GET"
+	}
+
+	var (
+		resp    *http.Response
+		latency time.Duration
+		err     error
+	)
+	for attempt := 1; ; attempt++ {
+		resp, latency, err = c.spiderRequest(method, task.URL)
+		// Не все серверы поддерживают HEAD
+		if err == nil && method == "HEAD" &&
+			(resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp.Body.Close()
+			method = "GET"
+			resp, latency, err = c.spiderRequest(method, task.URL)
+		}
+		if err == nil && resp.StatusCode >= 400 {
+			err = newHTTPError(resp)
+			resp.Body.Close()
+		}
+		if err == nil || !isRetryable(err) || attempt >= c.opts.Tries {
+			break
+		}
+		if !c.sleep(c.retryDelay(attempt, err)) {
+			break
+		}
+	}
+	if c.ctx.Err() != nil {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return
+	}
+
+	var httpErr *httpError
+	switch {
+	case err == nil:
+		c.spider.SetResult(task.URL, resp.StatusCode, latency, nil)
+	case errors.As(err, &httpErr):
+		c.spider.SetResult(task.URL, httpErr.StatusCode, latency, nil)
+		c.progress.Failed()
+		return
+	default:
+		c.spider.SetResult(task.URL, 0, latency, err)
+		c.progress.Failed()
+		return
+	}
+	defer resp.Body.Close()
+	c.progress.FileDone()
+	c.fetched(Fetched{
+		URL:         task.URL,
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	})
+
+	if !crawl || !isHTMLContent(resp.Header.Get("Content-Type")) {
+		return
+	}
+
+	content, err := io.ReadAll(io.LimitReader(io.TeeReader(c.limiter.Reader(c.ctx, resp.Body), progressWriter{progress: c.progress}), maxSpiderPageSize))
+	if err != nil {
+		c.progress.Logf("Ошибка чтения %s: %v", task.URL, err)
+		return
+	}
+
+	// Ссылки разрешаются от конечного адреса после редиректов
+	baseURL := resp.Request.URL
+	links, resources, frames := parseHTMLSimple(toUTF8(content, resp.Header.Get("Content-Type")), baseURL)
+	c.enqueueChildren(task.URL, task, links, resources, frames)
+}
+
+// spiderRequest выполняет один запрос и измеряет время до получения заголовков
+func (c *Crawler) spiderRequest(method, urlStr string) (*http.Response, time.Duration, error) {
+	req, err := c.newRequest(method, urlStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	started := time.Now()
+	resp, err := c.client.Do(req)
+	return resp, time.Since(started), err
+}
@@ -0,0 +1,167 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSpiderReportsBrokenLinks(t *testing.T) {
+	var mu sync.Mutex
+	methods := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.URL.Path] = append(methods[r.URL.Path], r.Method)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="a.html">a</a> <a href="missing.html">m</a> <img src="img.png"> <img src="nohead.png">`))
+		case "/a.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="deep.html">deep</a>`))
+		case "/img.png":
+			w.Header().Set("Content-Type", "image/png")
+		case "/nohead.png":
+			// Сервер без поддержки HEAD
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("PNG"))
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<urlset><url><loc>/gone.html</loc></url></urlset>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, storage := runCrawl(t, Options{URL: srv.URL + "/", MaxDepth: 1, Workers: 1, Spider: true, Sitemap: true})
+
+	if got := storage.Names(); len(got) != 0 {
+		t.Errorf("spider saved %v", got)
+	}
+	// Ссылки последней глубины проверяются через HEAD и не разбираются
+	wantMethods := map[string]string{
+		"/":           "GET",
+		"/a.html":     "HEAD",
+		"/img.png":    "HEAD",
+		"/nohead.png": "HEAD GET",
+		"/gone.html":  "GET",
+	}
+	for path, want := range wantMethods {
+		if got := strings.Join(methods[path], " "); got != want {
+			t.Errorf("%s requested with %q, want %q", path, got, want)
+		}
+	}
+	if _, ok := methods["/deep.html"]; ok {
+		t.Error("link past MaxDepth checked")
+	}
+
+	type edge struct {
+		from, to string
+		status   int
+	}
+	want := []edge{
+		{"", "/", 200},
+		{"/", "/a.html", 200},
+		{"/", "/img.png", 200},
+		{"/", "/missing.html", 404},
+		{"/", "/nohead.png", 200},
+		{"/sitemap.xml", "/gone.html", 404},
+	}
+	edges := c.SpiderReport().Edges()
+	if len(edges) != len(want) {
+		t.Fatalf("edges %+v", edges)
+	}
+	for i, e := range edges {
+		from := strings.TrimPrefix(e.From, srv.URL)
+		if from != want[i].from || e.To != srv.URL+want[i].to || e.Status != want[i].status {
+			t.Errorf("edge %d: %+v, want %+v", i, e, want[i])
+		}
+	}
+
+	var text bytes.Buffer
+	broken, err := c.SpiderReport().Write(&text, "text")
+	if err != nil || broken != 2 {
+		t.Fatalf("text report: %d broken, %v", broken, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(text.String(), "\n"), "\n")
+	if len(lines) != len(want)+1 || lines[len(lines)-1] != "Проверено ссылок: 6, битых: 2" {
+		t.Errorf("text report:\n%s", text.String())
+	}
+	if !strings.HasPrefix(lines[0], "OK   200 ") || !strings.HasSuffix(lines[0], "(start) -> "+srv.URL+"/") {
+		t.Errorf("start line %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[3], "FAIL 404 ") || !strings.HasSuffix(lines[3], " -> "+srv.URL+"/missing.html") {
+		t.Errorf("broken line %q", lines[3])
+	}
+}
+
+func TestSpiderReportFormats(t *testing.T) {
+	r := NewSpiderReport()
+	r.AddEdge("", "http://example.com/")
+	r.AddEdge("http://example.com/", "http://example.com/ok.png")
+	r.AddEdge("http://example.com/", "http://example.com/gone.html")
+	r.AddEdge("http://example.com/", "http://down.example.com/")
+	r.AddEdge("http://example.com/", "http://example.com/unchecked.html")
+	r.SetResult("http://example.com/", 200, 0, nil)
+	r.SetResult("http://example.com/ok.png", 200, 0, nil)
+	r.SetResult("http://example.com/gone.html", 404, 0, nil)
+	r.SetResult("http://down.example.com/", 0, 0, errQuotaExceeded)
+
+	var out bytes.Buffer
+	broken, err := r.Write(&out, "JSON")
+	if err != nil || broken != 2 {
+		t.Fatalf("json: %d broken, %v", broken, err)
+	}
+	var report struct {
+		Total  int        `json:"total"`
+		Broken int        `json:"broken"`
+		Edges  []LinkEdge `json:"edges"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	// Непроверенная цель в отчёт не попадает
+	if report.Total != 4 || report.Broken != 2 || len(report.Edges) != 4 {
+		t.Errorf("json report %+v", report)
+	}
+	if e := report.Edges[1]; e.To != "http://down.example.com/" || e.Error != errQuotaExceeded.Error() || !e.Broken() {
+		t.Errorf("network error edge %+v", e)
+	}
+
+	out.Reset()
+	if _, err := r.Write(&out, "junit"); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("junit: %v\n%s", err, out.String())
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 4 || suite.Failures != 2 || len(suite.Cases) != 4 {
+		t.Fatalf("junit suite %+v", suite)
+	}
+	if tc := suite.Cases[0]; tc.ClassName != "(start)" || tc.Failure != nil {
+		t.Errorf("start case %+v", tc)
+	}
+	if f := suite.Cases[2].Failure; f == nil || f.Message != "HTTP 404" {
+		t.Errorf("404 case %+v", suite.Cases[2])
+	}
+	if f := suite.Cases[1].Failure; f == nil || f.Message != errQuotaExceeded.Error() {
+		t.Errorf("network error case %+v", suite.Cases[1])
+	}
+
+	if _, err := r.Write(&out, "csv"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,200 @@
+package mirror
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StateFileName имя файла состояния внутри выходной директории
+const StateFileName = ".mirror-state.json"
+
+// ResourceState хранит сведения о ранее скачанном ресурсе
+type ResourceState struct {
+	URL          string   `json:"url"`
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Hash         string   `json:"hash"`
+	LocalPath    string   `json:"local_path"`
+	ContentType  string   `json:"content_type,omitempty"`
+	Links        []string `json:"links,omitempty"`
+	Resources    []string `json:"resources,omitempty"`
+	Frames       []string `json:"frames,omitempty"` // фреймы среди Resources
+
+	// Для URL, ответившего редиректом: конечный адрес и все переходы до него
+	RedirectTo string   `json:"redirect_to,omitempty"`
+	Redirects  []string `json:"redirects,omitempty"`
+}
+
+// CrawlState состояние зеркалирования, сохраняемое между запусками.
+// Resources переживает все запуски и используется для условных запросов,
+// Frontier и Done заполнены только пока обход не завершён и нужны для возобновления.
+type CrawlState struct {
+	mu   sync.Mutex
+	path string
+
+	Resources map[string]*ResourceState `json:"resources"`
+	Frontier  map[string]DownloadTask   `json:"frontier,omitempty"`
+	Done      map[string]bool           `json:"done,omitempty"`
+}
+
+// NewCrawlState создает пустое состояние, которое Save запишет в path.
+// При пустом path состояние живёт только в памяти.
+func NewCrawlState(path string) *CrawlState {
+	return &CrawlState{
+		path:      path,
+		Resources: make(map[string]*ResourceState),
+		Frontier:  make(map[string]DownloadTask),
+		Done:      make(map[string]bool),
+	}
+}
+
+// LoadState читает состояние из файла path.
+// Если файла ещё нет, возвращается пустое состояние.
+func LoadState(path string) (*CrawlState, error) {
+	state := NewCrawlState(path)
+
+	data, err := os.ReadFile(state.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+
+	// Старые или урезанные файлы могут не содержать части полей
+	if state.Resources == nil {
+		state.Resources = make(map[string]*ResourceState)
+	}
+	if state.Frontier == nil {
+		state.Frontier = make(map[string]DownloadTask)
+	}
+	if state.Done == nil {
+		state.Done = make(map[string]bool)
+	}
+	return state, nil
+}
+
+// Interrupted сообщает, остался ли от прошлого запуска незавершённый обход
+func (s *CrawlState) Interrupted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Frontier) > 0
+}
+
+// PendingTasks возвращает задачи, не обработанные в прерванном запуске
+func (s *CrawlState) PendingTasks() []DownloadTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := make([]DownloadTask, 0, len(s.Frontier))
+	for _, task := range s.Frontier {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// DoneURLs возвращает адреса, уже обработанные в прерванном запуске
+func (s *CrawlState) DoneURLs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	urls := make([]string, 0, len(s.Done))
+	for u := range s.Done {
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// AddPending помещает задачу во фронтир
+func (s *CrawlState) AddPending(task DownloadTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Frontier[task.URL] = task
+}
+
+// MarkDone переносит задачу из фронтира в список обработанных
+func (s *CrawlState) MarkDone(urlStr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Frontier, urlStr)
+	s.Done[urlStr] = true
+}
+
+// Finish отмечает обход завершённым: фронтир больше не нужен
+func (s *CrawlState) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Frontier = make(map[string]DownloadTask)
+	s.Done = make(map[string]bool)
+}
+
+// Get возвращает копию сведений о ресурсе или nil, если ресурс не скачивался
+func (s *CrawlState) Get(urlStr string) *ResourceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, ok := s.Resources[urlStr]
+	if !ok {
+		return nil
+	}
+	cp := *res
+	return &cp
+}
+
+// Update сохраняет сведения о скачанном ресурсе
+func (s *CrawlState) Update(res *ResourceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Resources[res.URL] = res
+}
+
+// setLocalPath обновляет путь файла ресурса после переноса
+func (s *CrawlState) setLocalPath(urlStr, localPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if res, ok := s.Resources[urlStr]; ok {
+		res.LocalPath = localPath
+	}
+}
+
+// relocate переводит локальные пути, записанные вместе с выходным
+// каталогом root (так хранили их прежние версии), в пути внутри хранилища
+func (s *CrawlState) relocate(root string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := filepath.Clean(root) + string(filepath.Separator)
+	for _, res := range s.Resources {
+		if strings.HasPrefix(res.LocalPath, prefix) {
+			res.LocalPath = strings.TrimPrefix(res.LocalPath, prefix)
+		}
+	}
+}
+
+// Save атомарно записывает состояние на диск через временный файл.
+// Состояние без файла не сохраняется.
+func (s *CrawlState) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,168 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRerunSendsConditionalRequests(t *testing.T) {
+	var conditional atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<img src="img.png">`))
+		case "/img.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("PNG"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	opts := Options{
+		URL:       srv.URL + "/",
+		MaxDepth:  1,
+		StateFile: filepath.Join(t.TempDir(), StateFileName),
+	}
+	_, storage := runCrawl(t, opts)
+	if n := conditional.Load(); n != 0 {
+		t.Fatalf("first run sent %d conditional requests", n)
+	}
+
+	// Второй запуск: 304 на всё, а ссылки берутся из состояния
+	var mu sync.Mutex
+	statuses := make(map[string]int)
+	opts.Storage = storage
+	opts.OnFetched = func(f Fetched) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[f.URL] = f.StatusCode
+	}
+	runCrawl(t, opts)
+
+	if n := conditional.Load(); n != 2 {
+		t.Errorf("second run sent %d conditional requests, want 2", n)
+	}
+	for _, u := range []string{srv.URL + "/", srv.URL + "/img.png"} {
+		if statuses[u] != http.StatusNotModified {
+			t.Errorf("%s: status %d, want 304", u, statuses[u])
+		}
+	}
+	if got := fileString(t, storage, hostPrefix(srv)+"/img.png"); got != "PNG" {
+		t.Errorf("img.png = %q after 304", got)
+	}
+
+	// Без локального файла условный запрос не отправляется
+	opts.Storage = NewMemoryStorage()
+	runCrawl(t, opts)
+	if n := conditional.Load(); n != 2 {
+		t.Errorf("run without files sent %d more conditional requests", n-2)
+	}
+}
+
+func TestRunResumesFrontier(t *testing.T) {
+	srv, log := newTestSite(t, map[string]testPage{
+		"/":        {Body: `<a href="a.html">a</a> <a href="b.html">b</a>`},
+		"/a.html":  {Body: `a`},
+		"/b.html":  {Body: `<img src="img.png">`},
+		"/img.png": {Body: "PNG"},
+	})
+
+	// Прерванный запуск: стартовая страница и a.html обработаны, b.html в очереди
+	stateFile := filepath.Join(t.TempDir(), StateFileName)
+	state := NewCrawlState(stateFile)
+	for _, task := range []DownloadTask{
+		{URL: srv.URL + "/", Type: "html"},
+		{URL: srv.URL + "/a.html", Depth: 1, Type: "html"},
+		{URL: srv.URL + "/b.html", Depth: 1, Type: "html"},
+	} {
+		state.AddPending(task)
+	}
+	state.MarkDone(srv.URL + "/")
+	state.MarkDone(srv.URL + "/a.html")
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	runCrawl(t, Options{URL: srv.URL + "/", MaxDepth: 2, StateFile: stateFile})
+
+	if got, want := log.paths(), []string{"/b.html", "/img.png"}; !slices.Equal(got, want) {
+		t.Errorf("resumed run requested %v, want %v", got, want)
+	}
+	saved, err := LoadState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Interrupted() {
+		t.Errorf("frontier left after a finished run: %v", saved.PendingTasks())
+	}
+	if saved.Get(srv.URL+"/b.html") == nil {
+		t.Error("b.html missing from resources")
+	}
+}
+
+func TestCancelSavesFrontier(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/slow.html" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(`<a href="slow.html">slow</a>`))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stateFile := filepath.Join(t.TempDir(), StateFileName)
+	c, err := New(Options{
+		URL:       srv.URL + "/",
+		MaxDepth:  1,
+		Tries:     1,
+		StateFile: stateFile,
+		OnFetched: func(f Fetched) {
+			// slow.html уже в очереди: отменяем обход, пока он скачивается
+			time.AfterFunc(50*time.Millisecond, cancel)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want context.Canceled", err)
+	}
+
+	saved, err := LoadState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := saved.PendingTasks()
+	if len(pending) != 1 || pending[0].URL != srv.URL+"/slow.html" {
+		t.Errorf("pending %v, want slow.html", pending)
+	}
+	if c.Failures().Len() != 0 {
+		t.Error("cancelled task reported as a failure")
+	}
+}
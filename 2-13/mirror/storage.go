@@ -0,0 +1,291 @@
+package mirror
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Storage хранилище скачанных файлов. Имена — относительные пути,
+// которые назначает PathMapper; методы вызываются из воркеров конкурентно.
+type Storage interface {
+	// Put сохраняет файл целиком, заменяя прежнее содержимое
+	Put(name string, r io.Reader) error
+	// Open открывает сохранённый файл
+	Open(name string) (io.ReadCloser, error)
+	// Exists сообщает, сохранён ли файл
+	Exists(name string) bool
+}
+
+// fileMover хранилище, которое может забрать временный файл без копирования
+type fileMover interface {
+	moveFile(name, tmpPath string) error
+}
+
+// fileRenamer хранилище, которое может переименовать сохранённый файл
+type fileRenamer interface {
+	renameFile(from, to string) error
+}
+
+// renameFile переносит файл внутри хранилища. Хранилища без переименования
+// получают копию; старый файл в них остаётся.
+func renameFile(s Storage, from, to string) error {
+	if r, ok := s.(fileRenamer); ok {
+		return r.renameFile(from, to)
+	}
+
+	f, err := s.Open(from)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Put(to, f)
+}
+
+// putFile сохраняет временный файл в хранилище и удаляет его
+func putFile(s Storage, name, tmpPath string) error {
+	if m, ok := s.(fileMover); ok {
+		if err := m.moveFile(name, tmpPath); err == nil {
+			return nil
+		}
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer f.Close()
+	return s.Put(name, f)
+}
+
+// DirStorage хранит файлы в каталоге на диске
+type DirStorage struct {
+	root string
+}
+
+// NewDirStorage создает хранилище в каталоге root
+func NewDirStorage(root string) *DirStorage {
+	return &DirStorage{root: root}
+}
+
+// Root возвращает каталог хранилища
+func (s *DirStorage) Root() string {
+	return s.root
+}
+
+// path возвращает путь файла на диске
+func (s *DirStorage) path(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+// Put пишет файл через временный рядом с ним, чтобы замена была атомарной
+func (s *DirStorage) Put(name string, r io.Reader) error {
+	fullPath := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".put-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return s.moveFile(name, tmp.Name())
+}
+
+// moveFile переименовывает временный файл на место; на другой файловой
+// системе rename не сработает, и putFile скопирует файл через Put
+func (s *DirStorage) moveFile(name, tmpPath string) error {
+	fullPath := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		return err
+	}
+	return os.Chmod(fullPath, 0644)
+}
+
+// renameFile переименовывает файл внутри каталога хранилища
+func (s *DirStorage) renameFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path(to)), 0755); err != nil {
+		return err
+	}
+	return os.Rename(s.path(from), s.path(to))
+}
+
+// Open открывает файл на диске
+func (s *DirStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+// Exists проверяет, что по пути лежит обычный файл
+func (s *DirStorage) Exists(name string) bool {
+	if name == "" {
+		return false
+	}
+	info, err := os.Stat(s.path(name))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// MemoryStorage хранит файлы в памяти; подходит для тестов и встраивания
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage создает пустое хранилище в памяти
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+// Put сохраняет копию содержимого
+func (s *MemoryStorage) Put(name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[filepath.ToSlash(name)] = data
+	return nil
+}
+
+// renameFile переносит содержимое под новое имя
+func (s *MemoryStorage) renameFile(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, to = filepath.ToSlash(from), filepath.ToSlash(to)
+	data, ok := s.files[from]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: from, Err: fs.ErrNotExist}
+	}
+	delete(s.files, from)
+	s.files[to] = data
+	return nil
+}
+
+// Open открывает сохранённое содержимое на чтение
+func (s *MemoryStorage) Open(name string) (io.ReadCloser, error) {
+	data, ok := s.File(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Exists сообщает, сохранён ли файл
+func (s *MemoryStorage) Exists(name string) bool {
+	_, ok := s.File(name)
+	return ok
+}
+
+// File возвращает содержимое файла
+func (s *MemoryStorage) File(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[filepath.ToSlash(name)]
+	return data, ok
+}
+
+// Names возвращает имена сохранённых файлов по алфавиту
+func (s *MemoryStorage) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TarStorage собирает файлы в tar-архив. До Close файлы лежат во временном
+// каталоге: переписывание ссылок после обхода меняет уже сохранённые страницы,
+// а tar дописывается только в конец.
+type TarStorage struct {
+	*DirStorage
+	w io.Writer
+}
+
+// NewTarStorage создает хранилище, которое при Close запишет архив в w
+func NewTarStorage(w io.Writer) (*TarStorage, error) {
+	dir, err := os.MkdirTemp("", "mirror-tar-*")
+	if err != nil {
+		return nil, err
+	}
+	return &TarStorage{DirStorage: NewDirStorage(dir), w: w}, nil
+}
+
+// Close пишет архив с файлами в порядке имён и удаляет временный каталог
+func (s *TarStorage) Close() error {
+	defer os.RemoveAll(s.root)
+
+	var names []string
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	tw := tar.NewWriter(s.w)
+	for _, name := range names {
+		if err := s.writeEntry(tw, name); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeEntry добавляет в архив один файл
+func (s *TarStorage) writeEntry(tw *tar.Writer, name string) error {
+	f, err := os.Open(s.path(name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime().Truncate(time.Second),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
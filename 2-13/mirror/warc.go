@@ -0,0 +1,522 @@
+package mirror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecordedErrorBody до скольких байт дочитывается для WARC тело, которое
+// клиент не прочитал сам: ответы с ошибкой, редиректы, прерванные загрузки
+const maxRecordedErrorBody = 1 << 20
+
+// WARCWriter пишет обмены запрос/ответ в архив WARC 1.1.
+// Каждая запись сжимается отдельным gzip-потоком, поэтому по смещению
+// из CDX-индекса запись можно прочитать без распаковки всего файла.
+type WARCWriter struct {
+	mu       sync.Mutex
+	file     *os.File
+	cdxPath  string
+	cdxLines []string // строки индекса, сортируются при закрытии
+	filename string   // имя файла архива для CDX
+	offset   int64    // смещение следующей записи
+	infoID   string   // WARC-Record-ID записи warcinfo
+}
+
+// warcPayload тело HTTP-ответа для записи в архив
+type warcPayload struct {
+	open      func() (io.ReadCloser, error)
+	size      int64
+	digest    string // "sha1:<base32>" от тела
+	truncated string // причина для WARC-Truncated, если тело записано не целиком
+}
+
+// warcField пара имя-значение в заголовках записи или блоке application/warc-fields
+type warcField struct {
+	name, value string
+}
+
+// NewWARCWriter создает NAME.warc.gz и пишет запись warcinfo.
+// Индекс NAME.cdx записывается при закрытии.
+func NewWARCWriter(name, software string) (*WARCWriter, error) {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".warc")
+
+	file, err := os.Create(name + ".warc.gz")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WARCWriter{
+		file:     file,
+		cdxPath:  name + ".cdx",
+		filename: filepath.Base(file.Name()),
+		infoID:   newRecordID(),
+	}
+
+	info := fieldsBlock([]warcField{
+		{"software", software},
+		{"format", "WARC File Format 1.1"},
+		{"conformsTo", "http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/"},
+		{"robots", "ignore"},
+	})
+	headers := []warcField{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", w.infoID},
+		{"WARC-Date", warcDate(time.Now())},
+		{"WARC-Filename", w.filename},
+		{"Content-Type", "application/warc-fields"},
+	}
+	if _, err := w.writeRecord(headers, bytesOpener(info), int64(len(info))); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeExchange записывает пару request/response и строку CDX.
+// Возвращает WARC-Record-ID записи response для связанных записей metadata.
+func (w *WARCWriter) writeExchange(req *http.Request, resp *http.Response, payload warcPayload) (string, error) {
+	date := warcDate(time.Now())
+	requestID, responseID := newRecordID(), newRecordID()
+	target := req.URL.String()
+
+	respHead := responseHead(resp)
+	blockDigest, err := digestOf(bytes.NewReader(respHead), payload.open)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Запись response: заголовок HTTP-ответа и тело
+	responseHeaders := []warcField{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"WARC-Warcinfo-ID", w.infoID},
+		{"WARC-Concurrent-To", requestID},
+		{"WARC-Block-Digest", blockDigest},
+		{"WARC-Payload-Digest", payload.digest},
+		{"WARC-Truncated", payload.truncated},
+		{"Content-Type", "application/http;msgtype=response"},
+	}
+	block := func() (io.ReadCloser, error) {
+		body, err := payload.open()
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{io.MultiReader(bytes.NewReader(respHead), body), body}, nil
+	}
+	offset := w.offset
+	compressed, err := w.writeRecord(responseHeaders, block, int64(len(respHead))+payload.size)
+	if err != nil {
+		return "", err
+	}
+
+	// Запись request
+	reqHead := requestHead(req)
+	requestHeaders := []warcField{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", requestID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"WARC-Warcinfo-ID", w.infoID},
+		{"WARC-Concurrent-To", responseID},
+		{"WARC-Block-Digest", sha1Digest(reqHead)},
+		{"Content-Type", "application/http;msgtype=request"},
+	}
+	if _, err := w.writeRecord(requestHeaders, bytesOpener(reqHead), int64(len(reqHead))); err != nil {
+		return "", err
+	}
+
+	// CDX: N b a m s k r M S V g
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	redirect := ""
+	if loc, err := resp.Location(); err == nil {
+		redirect = loc.String()
+	}
+	line := strings.Join([]string{
+		surtKey(req.URL),
+		strings.TrimSuffix(strings.NewReplacer("-", "", ":", "", "T", "").Replace(date), "Z"),
+		target,
+		orDash(mimeType),
+		strconv.Itoa(resp.StatusCode),
+		strings.TrimPrefix(payload.digest, "sha1:"),
+		orDash(redirect),
+		"-",
+		strconv.FormatInt(compressed, 10),
+		strconv.FormatInt(offset, 10),
+		w.filename,
+	}, " ")
+	w.cdxLines = append(w.cdxLines, line)
+	return responseID, nil
+}
+
+// writeMetadata пишет запись metadata, связанную с записью response
+func (w *WARCWriter) writeMetadata(target, refersTo string, fields []warcField) error {
+	block := fieldsBlock(fields)
+	headers := []warcField{
+		{"WARC-Type", "metadata"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", warcDate(time.Now())},
+		{"WARC-Target-URI", target},
+		{"WARC-Warcinfo-ID", w.infoID},
+		{"WARC-Refers-To", refersTo},
+		{"WARC-Block-Digest", sha1Digest(block)},
+		{"Content-Type", "application/warc-fields"},
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.writeRecord(headers, bytesOpener(block), int64(len(block)))
+	return err
+}
+
+// Close закрывает архив и записывает отсортированный CDX-индекс
+func (w *WARCWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	sort.Strings(w.cdxLines)
+	var b strings.Builder
+	b.WriteString(" CDX N b a m s k r M S V g\n")
+	for _, line := range w.cdxLines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(w.cdxPath, []byte(b.String()), 0644)
+}
+
+// writeRecord пишет одну запись отдельным gzip-потоком и возвращает его сжатый размер.
+// Вызывается под w.mu.
+func (w *WARCWriter) writeRecord(headers []warcField, open func() (io.ReadCloser, error), length int64) (int64, error) {
+	counter := &countingWriter{w: w.file}
+	zw := gzip.NewWriter(counter)
+
+	var head strings.Builder
+	head.WriteString("WARC/1.1\r\n")
+	for _, h := range headers {
+		if h.value != "" {
+			fmt.Fprintf(&head, "%s: %s\r\n", h.name, h.value)
+		}
+	}
+	fmt.Fprintf(&head, "Content-Length: %d\r\n\r\n", length)
+
+	if _, err := io.WriteString(zw, head.String()); err != nil {
+		return 0, err
+	}
+
+	block, err := open()
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.Copy(zw, block)
+	block.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := io.WriteString(zw, "\r\n\r\n"); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+
+	w.offset += counter.n
+	return counter.n, nil
+}
+
+// responseHead восстанавливает строку статуса и заголовки ответа
+func responseHead(resp *http.Response) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	resp.Header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// requestHead восстанавливает строку запроса и заголовки
+func requestHead(req *http.Request) []byte {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), host)
+	req.Header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// fieldsBlock форматирует блок application/warc-fields
+func fieldsBlock(fields []warcField) []byte {
+	var b bytes.Buffer
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s: %s\r\n", f.name, f.value)
+	}
+	return b.Bytes()
+}
+
+// surtKey строит ключ сортировки URL для CDX: "com,example)/path?query"
+func surtKey(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	key := host
+	// IP-адреса не переворачиваются
+	if net.ParseIP(host) == nil {
+		parts := strings.Split(host, ".")
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+		key = strings.Join(parts, ",")
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		key += ":" + port
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	key += ")" + p
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return strings.ToLower(key)
+}
+
+// formatDigest форматирует хеш SHA-1 в виде "sha1:<base32>"
+func formatDigest(sum []byte) string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum)
+}
+
+// sha1Digest считает дайджест блока в памяти
+func sha1Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return formatDigest(sum[:])
+}
+
+// digestOf считает дайджест заголовка и тела, читая тело потоком
+func digestOf(head io.Reader, open func() (io.ReadCloser, error)) (string, error) {
+	h := sha1.New()
+	if _, err := io.Copy(h, head); err != nil {
+		return "", err
+	}
+	body, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return formatDigest(h.Sum(nil)), nil
+}
+
+// bytesOpener возвращает функцию, отдающую данные из памяти
+func bytesOpener(data []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// fileOpener возвращает функцию, открывающую файл на чтение
+func fileOpener(path string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+}
+
+// newRecordID генерирует WARC-Record-ID вида <urn:uuid:...> (UUID версии 4)
+func newRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// warcDate форматирует время для WARC-Date
+func warcDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// orDash подставляет "-" вместо пустого значения поля CDX
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// countingWriter считает записанные байты
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readCloser объединяет Reader с Closer исходного потока
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// warcRecordKey ключ контекста запроса, под которым лежит адрес для
+// WARC-Record-ID записи response
+type warcRecordKey struct{}
+
+// withWARCRecord привязывает к запросу строку, куда warcTransport запишет
+// WARC-Record-ID ответа. Запись создаётся при закрытии тела ответа.
+func withWARCRecord(req *http.Request, id *string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), warcRecordKey{}, id))
+}
+
+// warcTransport записывает обмены в архив в том виде, в каком они пришли
+// по сети: со сжатым телом и исходными заголовками. Стоит под
+// decodingTransport, поэтому WARC-Payload-Digest считается от байтов
+// из сети, а сохранение и разбор получают распакованное тело.
+type warcTransport struct {
+	base    http.RoundTripper
+	warc    *WARCWriter
+	tempDir string
+	logf    func(format string, args ...any)
+}
+
+func (t *warcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	spool, err := os.CreateTemp(t.tempDir, ".warc-*")
+	if err != nil {
+		t.logf("Ошибка записи WARC для %s: %v", req.URL, err)
+		return resp, nil
+	}
+
+	// Заголовки запоминаются до того, как их поменяют слои выше
+	raw := *resp
+	raw.Header = resp.Header.Clone()
+	resp.Body = &capturedBody{
+		raw:       resp.Body,
+		transport: t,
+		req:       req,
+		resp:      &raw,
+		spool:     spool,
+		hash:      sha1.New(),
+	}
+	return resp, nil
+}
+
+// capturedBody копирует тело ответа во временный файл по мере чтения
+// и пишет обмен в архив в конце тела или при закрытии
+type capturedBody struct {
+	raw       io.ReadCloser
+	transport *warcTransport
+	req       *http.Request
+	resp      *http.Response
+	spool     *os.File
+	hash      hash.Hash
+	size      int64
+	eof       bool
+	err       error // ошибка записи во временный файл
+	done      bool
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.raw.Read(p)
+	b.capture(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// capture дописывает прочитанные байты во временный файл
+func (b *capturedBody) capture(p []byte) {
+	if len(p) == 0 || b.err != nil {
+		return
+	}
+	if _, err := b.spool.Write(p); err != nil {
+		b.err = err
+		return
+	}
+	b.hash.Write(p)
+	b.size += int64(len(p))
+}
+
+// Close дочитывает недочитанное тело (ответы 3xx и ошибки клиент
+// не читает), но не больше maxRecordedErrorBody, и пишет запись.
+// Тело, оборванное на пределе, помечается WARC-Truncated.
+func (b *capturedBody) Close() error {
+	if b.done {
+		return nil
+	}
+	b.done = true
+	defer os.Remove(b.spool.Name())
+	defer b.spool.Close()
+
+	// Хотя бы один байт дочитывается всегда: декодер сжатия может
+	// остановиться, не дойдя до EOF исходного тела
+	limit := max(b.size+1, maxRecordedErrorBody)
+	buf := make([]byte, 32<<10)
+	for !b.eof && b.err == nil && b.size < limit {
+		n, err := b.raw.Read(buf[:min(int64(len(buf)), limit-b.size)])
+		b.capture(buf[:n])
+		if err == io.EOF {
+			b.eof = true
+		} else if err != nil {
+			break
+		}
+	}
+	closeErr := b.raw.Close()
+	if b.err != nil {
+		b.transport.logf("Ошибка записи WARC для %s: %v", b.req.URL, b.err)
+		return closeErr
+	}
+
+	payload := warcPayload{
+		open:   fileOpener(b.spool.Name()),
+		size:   b.size,
+		digest: formatDigest(b.hash.Sum(nil)),
+	}
+	if !b.eof {
+		payload.truncated = "length"
+	}
+	id, err := b.transport.warc.writeExchange(b.req, b.resp, payload)
+	if err != nil {
+		b.transport.logf("Ошибка записи WARC для %s: %v", b.req.URL, err)
+	} else if slot, ok := b.req.Context().Value(warcRecordKey{}).(*string); ok {
+		*slot = id
+	}
+	return closeErr
+}
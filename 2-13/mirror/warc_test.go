@@ -0,0 +1,214 @@
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// warcRecord разобранная запись архива
+type warcRecord struct {
+	headers map[string]string
+	block   []byte
+}
+
+// readWARC читает все записи архива. Записи сжаты отдельными
+// gzip-потоками, gzip.Reader читает их подряд.
+func readWARC(t *testing.T, path string) []warcRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(zr)
+
+	var records []warcRecord
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return records
+		}
+		if line != "WARC/1.1\r\n" {
+			t.Fatalf("record %d starts with %q", len(records), line)
+		}
+		rec := warcRecord{headers: make(map[string]string)}
+		for {
+			line, err = r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\r\n" {
+				break
+			}
+			name, value, _ := strings.Cut(strings.TrimSuffix(line, "\r\n"), ": ")
+			rec.headers[name] = value
+		}
+		n, err := strconv.Atoi(rec.headers["Content-Length"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.block = make([]byte, n+4)
+		if _, err := io.ReadFull(r, rec.block); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasSuffix(rec.block, []byte("\r\n\r\n")) {
+			t.Fatalf("record %d not terminated", len(records))
+		}
+		rec.block = rec.block[:n]
+		records = append(records, rec)
+	}
+}
+
+// findRecord ищет запись по типу и адресу
+func findRecord(records []warcRecord, typ, target string) *warcRecord {
+	for i := range records {
+		if records[i].headers["WARC-Type"] == typ && records[i].headers["WARC-Target-URI"] == target {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+func TestWARCRecordsRawExchanges(t *testing.T) {
+	page := `<img src="img.png"> <a href="moved">moved</a>`
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(page))
+	zw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gz.Bytes())
+		case "/moved":
+			http.Redirect(w, r, "/target.html", http.StatusFound)
+		case "/target.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("target"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "crawl")
+	warc, err := NewWARCWriter(name, "test-agent/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, storage := runCrawl(t, Options{
+		URL:       srv.URL + "/",
+		MaxDepth:  1,
+		UserAgent: "test-agent/2",
+		WARC:      warc,
+	})
+	if err := warc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Сохраняется распакованная страница, в архив идут байты из сети
+	if got := fileString(t, storage, hostPrefix(srv)+"/index.html"); got != page {
+		t.Errorf("saved page %q", got)
+	}
+
+	records := readWARC(t, name+".warc.gz")
+	if records[0].headers["WARC-Type"] != "warcinfo" || !bytes.Contains(records[0].block, []byte("software: test-agent/2\r\n")) {
+		t.Errorf("warcinfo %v %q", records[0].headers, records[0].block)
+	}
+
+	resp := findRecord(records, "response", srv.URL+"/")
+	if resp == nil {
+		t.Fatal("no response record for the start page")
+	}
+	head, body, _ := bytes.Cut(resp.block, []byte("\r\n\r\n"))
+	if !bytes.Contains(head, []byte("Content-Encoding: gzip")) {
+		t.Errorf("Content-Encoding missing from the archived head:\n%s", head)
+	}
+	if !bytes.Equal(body, gz.Bytes()) {
+		t.Errorf("archived body is not the raw gzip stream")
+	}
+	if got, want := resp.headers["WARC-Payload-Digest"], sha1Digest(gz.Bytes()); got != want {
+		t.Errorf("payload digest %s, want %s", got, want)
+	}
+	if got, want := resp.headers["WARC-Block-Digest"], sha1Digest(resp.block); got != want {
+		t.Errorf("block digest %s, want %s", got, want)
+	}
+
+	req := findRecord(records, "request", srv.URL+"/")
+	if req == nil || !bytes.Contains(req.block, []byte("User-Agent: test-agent/2\r\n")) {
+		t.Errorf("request record missing or without User-Agent: %v", req)
+	}
+	if req != nil && req.headers["WARC-Concurrent-To"] != resp.headers["WARC-Record-ID"] {
+		t.Error("request is not linked to its response")
+	}
+
+	meta := findRecord(records, "metadata", srv.URL+"/")
+	if meta == nil || meta.headers["WARC-Refers-To"] != resp.headers["WARC-Record-ID"] {
+		t.Fatalf("metadata record missing or not linked: %v", meta)
+	}
+	if !bytes.Contains(meta.block, []byte("outlink: "+srv.URL+"/img.png E\r\n")) {
+		t.Errorf("metadata without the image outlink:\n%s", meta.block)
+	}
+
+	// Редирект и ошибка тоже архивируются
+	for target, status := range map[string]string{
+		srv.URL + "/moved":       "302",
+		srv.URL + "/target.html": "200",
+		srv.URL + "/img.png":     "404",
+	} {
+		rec := findRecord(records, "response", target)
+		if rec == nil || !bytes.HasPrefix(rec.block, []byte("HTTP/1.1 "+status)) {
+			t.Errorf("%s: no %s response record", target, status)
+		}
+	}
+
+	cdx, err := os.ReadFile(name + ".cdx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(cdx), "\n"), "\n")
+	if lines[0] != " CDX N b a m s k r M S V g" || len(lines) != 5 {
+		t.Fatalf("cdx:\n%s", cdx)
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 11 || fields[10] != "crawl.warc.gz" {
+			t.Errorf("cdx line %q", line)
+		}
+		if fields[2] == srv.URL+"/moved" && fields[6] != srv.URL+"/target.html" {
+			t.Errorf("redirect target missing: %q", line)
+		}
+	}
+}
+
+func TestSurtKey(t *testing.T) {
+	tests := map[string]string{
+		"http://www.Example.com/a/b?x=1": "com,example)/a/b?x=1",
+		"https://example.com":            "com,example)/",
+		"http://sub.example.co.uk:8080/": "uk,co,example,sub:8080)/",
+		"http://example.com:80/":         "com,example)/",
+		"http://127.0.0.1:9000/p%20q":    "127.0.0.1:9000)/p%20q",
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := surtKey(u); got != want {
+			t.Errorf("surtKey(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
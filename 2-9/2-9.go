@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 /*
@@ -39,105 +46,377 @@ import (
 Код должен быть статически анализируем (vet, golint).
 */
 
+// DefaultMaxOutput предел размера результата по умолчанию, 64 МиБ.
+// Короткий вход вроде "a999999999" иначе потребовал бы гигабайты памяти.
+const DefaultMaxOutput = 64 << 20
+
+// Виды ошибок распаковки; сопоставляются с *UnpackError через errors.Is
+var (
+	ErrLeadingDigit      = errors.New("string starts with a digit")
+	ErrTrailingBackslash = errors.New("trailing backslash")
+	ErrCountOverflow     = errors.New("repeat count overflows int")
+	ErrOutputTooLarge    = errors.New("output too large")
+
+	// Ошибки расширенного синтаксиса (Options.Groups)
+	ErrUnmatchedParen = errors.New("unmatched closing parenthesis")
+	ErrUnclosedGroup  = errors.New("unclosed group")
+	ErrNestingTooDeep = errors.New("groups nested too deep")
+)
+
+// UnpackError ошибка во входной строке с её положением
+type UnpackError struct {
+	Offset int64 // смещение в байтах от начала входа
+	Rune   rune  // руна, на которой обнаружена ошибка
+	Kind   error // один из Err*
+}
+
+func (e *UnpackError) Error() string {
+	return fmt.Sprintf("%v at offset %d (%q)", e.Kind, e.Offset, e.Rune)
+}
+
+// Unwrap позволяет проверять вид ошибки через errors.Is
+func (e *UnpackError) Unwrap() error {
+	return e.Kind
+}
+
+// Options настройки распаковки. Нулевое значение — настройки по умолчанию.
+type Options struct {
+	// MaxOutput предел размера результата в байтах:
+	// 0 — DefaultMaxOutput, отрицательное значение — без ограничения
+	MaxOutput int64
+
+	// Graphemes повторять графемный кластер целиком: "é3" с комбинирующим
+	// ударением или эмодзи с модификатором цвета кожи повторяются как один
+	// символ. Pack не учитывает кластеры, поэтому Unpack(Pack(x)) == x
+	// гарантируется только без этого режима.
+	Graphemes bool
+
+	// ASCIIDigits считать числом повторений только цифры 0–9; иначе
+	// подходят любые десятичные цифры Unicode (например, арабско-индийские)
+	ASCIIDigits bool
+
+	// Groups включает расширенный синтаксис: "(ab)3" даёт "ababab",
+	// группы вкладываются: "((a)2b)2" даёт "aabaab". Скобки во входе
+	// экранируются "\(" и "\)". Группа верхнего уровня разбирается целиком
+	// до вывода. Pack скобки не экранирует.
+	Groups bool
+
+	// MaxDepth предел вложенности групп: 0 — DefaultMaxDepth,
+	// отрицательное значение — без ограничения
+	MaxDepth int
+}
+
 // Unpack распаковывает строку, содержащую повторяющиеся символы/руны.
 // Поддерживает escape-последовательности вида \.
 // Возвращает распакованную строку и ошибку в случае некорректного ввода.
 func Unpack(s string) (string, error) {
-	if s == "" {
-		return "", nil
-	}
+	return Options{}.Unpack(s)
+}
 
+// UnpackTo распаковывает поток r в w с настройками по умолчанию
+func UnpackTo(w io.Writer, r io.Reader) error {
+	return Options{}.UnpackTo(w, r)
+}
+
+// Unpack распаковывает строку с заданными настройками
+func (o Options) Unpack(s string) (string, error) {
 	var builder strings.Builder
-	runes := []rune(s)
-	i := 0
-	escaped := false
+	if err := o.UnpackTo(&builder, strings.NewReader(s)); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
 
-	for i < len(runes) {
-		r := runes[i]
-		i++
+// UnpackTo распаковывает поток r в w по мере чтения: в памяти держится
+// только текущая руна и её счётчик. При ошибке в w может остаться уже
+// распакованное начало.
+func (o Options) UnpackTo(w io.Writer, r io.Reader) error {
+	limit := o.MaxOutput
+	if limit == 0 {
+		limit = DefaultMaxOutput
+	}
+	maxDepth := o.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
 
-		if escaped {
-			// Экранированный символ — просто добавляем его
-			builder.WriteRune(r)
-			escaped = false
-			continue
+	u := &unpacker{
+		in:          bufio.NewReader(r),
+		out:         bufio.NewWriter(w),
+		limit:       limit,
+		graphemes:   o.Graphemes,
+		asciiDigits: o.ASCIIDigits,
+		groups:      o.Groups,
+		maxDepth:    maxDepth,
+	}
+	run := u.run
+	if u.groups {
+		run = u.runGroups
+	}
+	if err := run(); err != nil {
+		u.out.Flush()
+		return err
+	}
+	return u.out.Flush()
+}
+
+// unpacker состояние потоковой распаковки
+type unpacker struct {
+	in      *bufio.Reader
+	out     *bufio.Writer
+	limit   int64 // < 0 — без ограничения
+	written int64
+	offset  int64 // смещение следующей руны во входе
+
+	graphemes   bool
+	asciiDigits bool
+	groups      bool
+	maxDepth    int // < 0 — без ограничения
+
+	unit    []byte // повторяемая руна или кластер в UTF-8
+	cluster []rune // руны текущего кластера в режиме Graphemes
+}
+
+// run разбирает вход за один проход: руна, за которой идут цифры,
+// повторяется указанное число раз, экранированная руна тоже может
+// повторяться. Цифра на месте руны бывает только в начале входа.
+func (u *unpacker) run() error {
+	for {
+		offset := u.offset
+		r, err := u.readRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 
 		if r == '\\' {
 			// Начало escape-последовательности
-			if i >= len(runes) {
+			r, err = u.readRune()
+			if err == io.EOF {
 				// Обратный слэш в конце строки — ошибка
-				return "", errors.New("trailing backslash")
+				return &UnpackError{Offset: offset, Rune: '\\', Kind: ErrTrailingBackslash}
 			}
-			escaped = true
-			continue
-		}
-
-		// Обычный символ
-		if i < len(runes) && unicode.IsDigit(runes[i]) {
-			// Следующий символ — цифра, читаем всё число
-			start := i
-			for i < len(runes) && unicode.IsDigit(runes[i]) {
-				i++
-			}
-			numStr := string(runes[start:i])
-			count, err := strconv.Atoi(numStr)
 			if err != nil {
-				// Не должно произойти, но на всякий случай
-				return "", errors.New("invalid number")
-			}
-			if count == 0 {
-				// Пропускаем символ, если указано 0 повторений
-				continue
+				return err
 			}
-			for j := 0; j < count; j++ {
-				builder.WriteRune(r)
+		} else if u.isDigit(r) {
+			return &UnpackError{Offset: offset, Rune: r, Kind: ErrLeadingDigit}
+		}
+
+		u.unit = utf8.AppendRune(u.unit[:0], r)
+		if u.graphemes {
+			if err := u.readCluster(r); err != nil {
+				return err
 			}
-		} else {
-			// Следующий символ — не цифра, просто добавляем текущий символ один раз
-			builder.WriteRune(r)
 		}
+
+		count, err := u.readCount()
+		if err != nil {
+			return err
+		}
+		if err := u.repeat(r, count, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// readRune читает следующую руну входа
+func (u *unpacker) readRune() (rune, error) {
+	r, size, err := u.in.ReadRune()
+	u.offset += int64(size)
+	return r, err
+}
+
+// readCluster дочитывает руны, продолжающие графемный кластер,
+// который начинается с first, и дописывает их в u.unit. Цифры, обратный
+// слэш и скобки групп остаются синтаксисом: иначе после знака Prepend
+// (GB9b) они вошли бы в кластер.
+func (u *unpacker) readCluster(first rune) error {
+	u.cluster = append(u.cluster[:0], first)
+	for {
+		offset := u.offset
+		r, err := u.readRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if u.isSyntax(r) || !continuesCluster(u.cluster, r) {
+			u.offset = offset
+			return u.in.UnreadRune()
+		}
+		u.cluster = append(u.cluster, r)
+		u.unit = utf8.AppendRune(u.unit, r)
 	}
+}
 
-	// Проверка: если строка заканчивается на обратный слэш
-	if escaped {
-		return "", errors.New("trailing backslash")
+// isDigit проверяет, является ли руна цифрой счётчика
+func (u *unpacker) isDigit(r rune) bool {
+	if u.asciiDigits {
+		return r >= '0' && r <= '9'
 	}
+	return unicode.IsDigit(r)
+}
 
-	result := builder.String()
+// isSyntax проверяет, является ли руна частью синтаксиса, а не текста
+func (u *unpacker) isSyntax(r rune) bool {
+	return u.isDigit(r) || r == '\\' || u.groups && (r == '(' || r == ')')
+}
 
-	// Дополнительная проверка: если результат пустой, но вход не был пустым,
-	// и строка не содержит ни одного валидного символа (например, "45"),
-	// то это ошибка.
-	if result == "" && len(s) > 0 {
-		// Проверим, состоит ли исходная строка только из цифр и/или слэшей без валидных символов
-		hasValidChar := false
-		escapedCheck := false
-		for _, r := range s {
-			if escapedCheck {
-				// Экранированный символ считается валидным
-				hasValidChar = true
-				escapedCheck = false
-				continue
-			}
-			if r == '\\' {
-				escapedCheck = true
-				continue
-			}
-			if !unicode.IsDigit(r) {
-				hasValidChar = true
-				break
+// readCount читает число повторений после руны; без цифр — 1.
+// Число накапливается по мере чтения, переполнение int — ошибка.
+func (u *unpacker) readCount() (int, error) {
+	count, digits := 0, 0
+	for {
+		offset := u.offset
+		r, err := u.readRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !u.isDigit(r) {
+			if err := u.in.UnreadRune(); err != nil {
+				return 0, err
 			}
+			u.offset = offset
+			break
+		}
+
+		d := digitValue(r)
+		if count > (math.MaxInt-d)/10 {
+			return 0, &UnpackError{Offset: offset, Rune: r, Kind: ErrCountOverflow}
+		}
+		count = count*10 + d
+		digits++
+	}
+
+	if digits == 0 {
+		return 1, nil
+	}
+	return count, nil
+}
+
+// digitValue возвращает значение десятичной цифры любой письменности.
+// Цифры Unicode (категория Nd) идут блоками по десять от 0 до 9,
+// поэтому значение — остаток от смещения внутри диапазона таблицы.
+func digitValue(r rune) int {
+	if r >= '0' && r <= '9' {
+		return int(r - '0')
+	}
+	for _, rng := range unicode.Nd.R16 {
+		if r >= rune(rng.Lo) && r <= rune(rng.Hi) {
+			return int(r-rune(rng.Lo)) % 10
 		}
-		if !hasValidChar {
-			return "", errors.New("string contains only digits or invalid sequences")
+	}
+	for _, rng := range unicode.Nd.R32 {
+		if r >= rune(rng.Lo) && r <= rune(rng.Hi) {
+			return int(r-rune(rng.Lo)) % 10
 		}
 	}
+	return 0
+}
+
+// repeat пишет u.unit count раз, заранее проверяя предел размера;
+// r и offset — первая руна и её положение во входе для ошибки
+func (u *unpacker) repeat(r rune, count int, offset int64) error {
+	size := int64(len(u.unit))
+	if u.limit >= 0 && int64(count) > (u.limit-u.written)/size {
+		return &UnpackError{Offset: offset, Rune: r, Kind: ErrOutputTooLarge}
+	}
 
-	return result, nil
+	for j := 0; j < count; j++ {
+		if _, err := u.out.Write(u.unit); err != nil {
+			return err
+		}
+	}
+	u.written += int64(count) * size
+	return nil
+}
+
+// Pack обратна Unpack: серии одинаковых рун записываются как руна и число
+// повторений, цифры и обратный слэш экранируются \\, поэтому
+// Unpack(Pack(x)) == x для любой строки в корректном UTF-8 (если результат
+// укладывается в MaxOutput). Счётчик ставится, только когда он короче
+// повторения руны.
+func Pack(s string) string {
+	var builder strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		n := 1
+		for i+n < len(runes) && runes[i+n] == r {
+			n++
+		}
+		i += n
+
+		unit := string(r)
+		if r == '\\' || unicode.IsDigit(r) {
+			unit = "\\" + unit
+		}
+		count := strconv.Itoa(n)
+		if len(unit)*n > len(unit)+len(count) {
+			builder.WriteString(unit)
+			builder.WriteString(count)
+		} else {
+			builder.WriteString(strings.Repeat(unit, n))
+		}
+	}
+	return builder.String()
 }
 
 func main() {
+	maxOutput := flag.Int64("max-output", DefaultMaxOutput, "Предел размера результата в байтах (отрицательное значение — без ограничения)")
+	examples := flag.Bool("examples", false, "Показать примеры вместо распаковки STDIN (по умолчанию, если STDIN — терминал и флагов нет)")
+	pack := flag.Bool("pack", false, "Упаковать STDIN вместо распаковки")
+	graphemes := flag.Bool("graphemes", false, "Повторять графемный кластер целиком, а не последнюю руну")
+	asciiDigits := flag.Bool("ascii-digits", false, "Считать числом повторений только цифры 0-9")
+	groups := flag.Bool("groups", false, "Расширенный синтаксис: повторение групп в скобках, например (ab)3")
+	maxDepth := flag.Int("max-depth", DefaultMaxDepth, "Предел вложенности групп (отрицательное значение — без ограничения)")
+	flag.Parse()
+
+	// Запуск без флагов и без перенаправленного ввода, как раньше,
+	// показывает примеры, а не ждёт ввода с клавиатуры
+	if *examples || (flag.NFlag() == 0 && isTerminal(os.Stdin)) {
+		printExamples()
+		return
+	}
+	if *pack {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(Pack(string(input)))
+		return
+	}
+
+	// STDIN распаковывается в STDOUT потоком
+	opts := Options{
+		MaxOutput:   *maxOutput,
+		Graphemes:   *graphemes,
+		ASCIIDigits: *asciiDigits,
+		Groups:      *groups,
+		MaxDepth:    *maxDepth,
+	}
+	if err := opts.UnpackTo(os.Stdout, os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// isTerminal сообщает, что файл — терминал, а не канал или файл
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printExamples распаковывает примеры из условия
+func printExamples() {
 	// тесты функции и примеры
 	examples := []string{
 		"a4bc2d5e",
@@ -156,7 +435,26 @@ func main() {
 		if err != nil {
 			println("Input:", ex, "=> Error:", err.Error())
 		} else {
-			println("Input:", ex, "=> Output:", res)
+			println("Input:", ex, "=> Output:", res, "=> Pack:", Pack(res))
+		}
+	}
+
+	// Расширенный синтаксис с группами
+	groups := []string{
+		"(ab)3",
+		"((a)2b)2",
+		"x(yz)0w",
+		"\\(a\\)2",
+		"(ab",
+		"ab)",
+	}
+
+	for _, ex := range groups {
+		res, err := Options{Groups: true}.Unpack(ex)
+		if err != nil {
+			println("Groups:", ex, "=> Error:", err.Error())
+		} else {
+			println("Groups:", ex, "=> Output:", res)
 		}
 	}
 }
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzMaxOutput предел результата в фаззинге: короткий вход вроде "a99999"
+// не должен превращать каждую итерацию в запись мегабайт
+const fuzzMaxOutput = 1 << 16
+
+// examples входы из условия задачи и printExamples
+var examples = []string{
+	"a4bc2d5e",
+	"abcd",
+	"45",
+	"",
+	`qwe\4\5`,
+	`qwe\45`,
+	`\3\2`,
+	"3",
+	`\`,
+	"(ab)3",
+	"((a)2b)2",
+	"x(yz)0w",
+	`\(a\)2`,
+	"(ab",
+	"ab)",
+}
+
+func FuzzPackRoundTrip(f *testing.F) {
+	for _, ex := range examples {
+		f.Add(ex)
+	}
+	f.Add("aaaabccddddde")
+	f.Add("qwe44444")
+	f.Add(`\\\\`)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) || len(s) > DefaultMaxOutput {
+			t.Skip()
+		}
+		packed := Pack(s)
+		got, err := Unpack(packed)
+		if err != nil {
+			t.Fatalf("Unpack(Pack(%q)) = %q: %v", s, packed, err)
+		}
+		if got != s {
+			t.Fatalf("Unpack(Pack(%q)) = %q, packed %q", s, got, packed)
+		}
+	})
+}
+
+func FuzzUnpack(f *testing.F) {
+	for _, ex := range examples {
+		f.Add(ex)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		base := Options{MaxOutput: fuzzMaxOutput}
+		plain, plainErr := unpackChecked(t, base, s)
+
+		graphemes := base
+		graphemes.Graphemes = true
+		unpackChecked(t, graphemes, s)
+
+		// На входе без не-ASCII цифр ASCIIDigits ничего не меняет
+		ascii := base
+		ascii.ASCIIDigits = true
+		got, err := unpackChecked(t, ascii, s)
+		if isASCII(s) && (got != plain || (err == nil) != (plainErr == nil)) {
+			t.Fatalf("ASCIIDigits(%q) = %q, %v; default %q, %v", s, got, err, plain, plainErr)
+		}
+
+		// Без скобок расширенный синтаксис совпадает с обычным
+		groups := base
+		groups.Groups = true
+		got, err = unpackChecked(t, groups, s)
+		if !strings.ContainsAny(s, "()") && (got != plain || (err == nil) != (plainErr == nil)) {
+			t.Fatalf("Groups(%q) = %q, %v; default %q, %v", s, got, err, plain, plainErr)
+		}
+	})
+}
+
+// unpackChecked распаковывает s и проверяет общие для всех режимов
+// свойства: результат не больше предела, ошибка — *UnpackError
+func unpackChecked(t *testing.T, o Options, s string) (string, error) {
+	t.Helper()
+	got, err := o.Unpack(s)
+	if err != nil {
+		var unpackErr *UnpackError
+		if !errors.As(err, &unpackErr) {
+			t.Fatalf("%+v.Unpack(%q): unexpected error type %T: %v", o, s, err, err)
+		}
+		if unpackErr.Offset < 0 || unpackErr.Offset > int64(len(s)) {
+			t.Fatalf("%+v.Unpack(%q): offset %d out of range", o, s, unpackErr.Offset)
+		}
+		return "", err
+	}
+	if int64(len(got)) > o.MaxOutput {
+		t.Fatalf("%+v.Unpack(%q): %d bytes over limit %d", o, s, len(got), o.MaxOutput)
+	}
+	return got, nil
+}
+
+// isASCII сообщает, состоит ли строка только из ASCII
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// unpackTest вход и ожидаемый результат распаковки; при err ожидается
+// *UnpackError с этой причиной и смещением offset
+type unpackTest struct {
+	name   string
+	opts   Options
+	in     string
+	want   string
+	err    error
+	offset int64
+}
+
+// checkUnpack распаковывает вход каждого теста и сверяет результат
+func checkUnpack(t *testing.T, tests []unpackTest) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := tt.opts.Unpack(tt.in)
+		if tt.err == nil {
+			if err != nil || got != tt.want {
+				t.Errorf("%s: Unpack(%q) = %q, %v; want %q", tt.name, tt.in, got, err, tt.want)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Unpack(%q) error %v, want %v", tt.name, tt.in, err, tt.err)
+			continue
+		}
+		var unpackErr *UnpackError
+		if !errors.As(err, &unpackErr) {
+			t.Errorf("%s: Unpack(%q) error type %T", tt.name, tt.in, err)
+			continue
+		}
+		if unpackErr.Offset != tt.offset {
+			t.Errorf("%s: Unpack(%q) offset %d, want %d", tt.name, tt.in, unpackErr.Offset, tt.offset)
+		}
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	checkUnpack(t, []unpackTest{
+		{name: "repeats", in: "a4bc2d5e", want: "aaaabccddddde"},
+		{name: "no digits", in: "abcd", want: "abcd"},
+		{name: "empty", in: "", want: ""},
+		{name: "escaped digits", in: `qwe\4\5`, want: "qwe45"},
+		{name: "escaped digit repeated", in: `qwe\45`, want: "qwe44444"},
+		{name: "escaped backslash", in: `a\\3`, want: `a\\\`},
+		{name: "zero count", in: "ab0c", want: "ac"},
+		{name: "multi-digit count", in: "a12", want: "aaaaaaaaaaaa"},
+		{name: "leading digit", in: "45", err: ErrLeadingDigit, offset: 0},
+		{name: "only digit", in: "3", err: ErrLeadingDigit, offset: 0},
+		{name: "trailing backslash", in: `qwe\`, err: ErrTrailingBackslash, offset: 3},
+		{name: "lone backslash", in: `\`, err: ErrTrailingBackslash, offset: 0},
+		{name: "count overflow", in: "a99999999999999999999", err: ErrCountOverflow, offset: 19},
+		{name: "output too large", opts: Options{MaxOutput: 10}, in: "ab5c6", err: ErrOutputTooLarge, offset: 3},
+		{name: "single repeat too large", opts: Options{MaxOutput: 10}, in: "a11", err: ErrOutputTooLarge, offset: 0},
+		{name: "exactly at limit", opts: Options{MaxOutput: 10}, in: "a10", want: "aaaaaaaaaa"},
+	})
+}
+
+func TestUnpackGraphemes(t *testing.T) {
+	g := Options{Graphemes: true}
+	checkUnpack(t, []unpackTest{
+		{name: "combining acute", opts: g, in: "e\u03013", want: "e\u0301e\u0301e\u0301"},
+		{name: "combining acute, last rune only", in: "e\u03013", want: "e\u0301\u0301\u0301"},
+		{name: "two combining marks", opts: g, in: "xe\u0301\u03082", want: "xe\u0301\u0308e\u0301\u0308"},
+		{name: "skin tone", opts: g, in: "\U0001F44D\U0001F3FD2", want: "\U0001F44D\U0001F3FD\U0001F44D\U0001F3FD"},
+		{name: "ZWJ family", opts: g, in: "\U0001F468\u200d\U0001F469\u200d\U0001F4672",
+			want: "\U0001F468\u200d\U0001F469\u200d\U0001F467\U0001F468\u200d\U0001F469\u200d\U0001F467"},
+		{name: "ZWJ after a letter", opts: g, in: "a\u200d\U0001F4672", want: "a\u200d\U0001F467\U0001F467"},
+		{name: "flag", opts: g, in: "\U0001F1F7\U0001F1FA2", want: "\U0001F1F7\U0001F1FA\U0001F1F7\U0001F1FA"},
+		{name: "two flags", opts: g, in: "\U0001F1F7\U0001F1FA\U0001F1EF\U0001F1F52",
+			want: "\U0001F1F7\U0001F1FA\U0001F1EF\U0001F1F5\U0001F1EF\U0001F1F5"},
+		{name: "odd regional indicator", opts: g, in: "\U0001F1F7\U0001F1FA\U0001F1F73",
+			want: "\U0001F1F7\U0001F1FA\U0001F1F7\U0001F1F7\U0001F1F7"},
+		{name: "hangul L+V+T", opts: g, in: "\u1100\u1161\u11A82", want: "\u1100\u1161\u11A8\u1100\u1161\u11A8"},
+		{name: "hangul LV+T", opts: g, in: "\uAC00\u11A82", want: "\uAC00\u11A8\uAC00\u11A8"},
+		{name: "hangul syllables", opts: g, in: "\uAC00\uAC012", want: "\uAC00\uAC01\uAC01"},
+		{name: "prepend", opts: g, in: "\u0600a2", want: "\u0600a\u0600a"},
+		{name: "CR LF", opts: g, in: "x\r\n2", want: "x\r\n\r\n"},
+		{name: "LF CR", opts: g, in: "\n\r2", want: "\n\r\r"},
+		{name: "control breaks", opts: g, in: "\t\u03012", want: "\t\u0301\u0301"},
+		{name: "escaped digit with mark", opts: g, in: `\4` + "\u03012", want: "4\u03014\u0301"},
+	})
+}
+
+func TestUnpackGroups(t *testing.T) {
+	g := Options{Groups: true}
+	checkUnpack(t, []unpackTest{
+		{name: "group", opts: g, in: "(ab)3", want: "ababab"},
+		{name: "nested", opts: g, in: "((a)2b)2", want: "aabaab"},
+		{name: "zero count", opts: g, in: "x(yz)0w", want: "xw"},
+		{name: "no count", opts: g, in: "x(yz)w", want: "xyzw"},
+		{name: "escaped parens", opts: g, in: `\(a\)2`, want: "(a))"},
+		{name: "parens without Groups", in: "(ab)3", want: "(ab)))"},
+		{name: "unclosed", opts: g, in: "(ab", err: ErrUnclosedGroup, offset: 0},
+		{name: "unclosed inner", opts: g, in: "x(a(b)", err: ErrUnclosedGroup, offset: 1},
+		{name: "unmatched", opts: g, in: "ab)", err: ErrUnmatchedParen, offset: 2},
+		{name: "at max depth", opts: Options{Groups: true, MaxDepth: 2}, in: "((a))2", want: "aa"},
+		{name: "past max depth", opts: Options{Groups: true, MaxDepth: 2}, in: "a(((b)))", err: ErrNestingTooDeep, offset: 3},
+		{name: "unlimited depth", opts: Options{Groups: true, MaxDepth: -1},
+			in: strings.Repeat("(", DefaultMaxDepth+1) + "a" + strings.Repeat(")", DefaultMaxDepth+1), want: "a"},
+		{name: "past default depth", opts: g,
+			in:  strings.Repeat("(", DefaultMaxDepth+1) + "a" + strings.Repeat(")", DefaultMaxDepth+1),
+			err: ErrNestingTooDeep, offset: DefaultMaxDepth},
+		{name: "count overflow", opts: g, in: "(a)99999999999999999999", err: ErrCountOverflow, offset: 21},
+		{name: "nested repeat too large", opts: Options{Groups: true, MaxOutput: 100}, in: "((ab)10c)10",
+			err: ErrOutputTooLarge, offset: 0},
+		{name: "nested repeat at limit", opts: Options{Groups: true, MaxOutput: 105}, in: "((ab)10c)5",
+			want: strings.Repeat(strings.Repeat("ab", 10)+"c", 5)},
+	})
+}
@@ -0,0 +1,226 @@
+package main
+
+import "unicode"
+
+// Упрощённая сегментация на расширенные графемные кластеры по UAX #29,
+// без внешних зависимостей. Поддерживаются правила, которые встречаются
+// в обычном тексте: CR LF, управляющие символы, слоги хангыля,
+// комбинирующие и пробельные знаки (Extend, SpacingMark), знаки Prepend,
+// ZWJ-последовательности эмодзи и пары региональных индикаторов (флаги).
+// Таблицы Prepend и Extended_Pictographic взяты из Unicode 15;
+// правило GB9c (конъюнкты индийских письменностей) не поддерживается.
+
+const (
+	zwnj = '\u200c'
+	zwj  = '\u200d'
+)
+
+// continuesCluster сообщает, продолжает ли руна next кластер cluster
+// (между ними нет границы графемы)
+func continuesCluster(cluster []rune, next rune) bool {
+	prev := cluster[len(cluster)-1]
+	switch {
+	case prev == '\r':
+		return next == '\n' // GB3, иначе GB4
+	case isControl(prev), isControl(next):
+		return false // GB4, GB5
+	case hangulJoins(prev, next):
+		return true // GB6–GB8
+	case isExtend(next), next == zwj, unicode.Is(unicode.Mc, next):
+		return true // GB9, GB9a
+	case unicode.Is(prepend, prev):
+		return true // GB9b
+	case prev == zwj && isPictographic(next):
+		return emojiBeforeZWJ(cluster[:len(cluster)-1]) // GB11
+	case isRegionalIndicator(prev) && isRegionalIndicator(next):
+		return regionalRun(cluster)%2 == 1 // GB12, GB13
+	}
+	return false
+}
+
+// isControl класс Control: управляющие символы и разделители строк
+// и абзацев, а также форматирующие символы, кроме ZWNJ, ZWJ, тегов и Prepend
+func isControl(r rune) bool {
+	if r == zwnj || r == zwj || isTag(r) || unicode.Is(prepend, r) {
+		return false
+	}
+	return unicode.In(r, unicode.Cc, unicode.Zl, unicode.Zp, unicode.Cf)
+}
+
+// isExtend класс Extend: комбинирующие знаки, ZWNJ, модификаторы
+// цвета кожи и теги эмодзи
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) ||
+		r == zwnj ||
+		r >= 0x1F3FB && r <= 0x1F3FF ||
+		isTag(r)
+}
+
+// isTag теги эмодзи, из которых собираются флаги регионов
+func isTag(r rune) bool {
+	return r >= 0xE0020 && r <= 0xE007F
+}
+
+// isPictographic свойство Extended_Pictographic
+func isPictographic(r rune) bool {
+	return unicode.Is(extendedPictographic, r)
+}
+
+// emojiBeforeZWJ проверяет левую часть правила GB11: кластер перед ZWJ
+// заканчивается на ExtPict Extend*. Иначе ZWJ после буквы или цифры
+// склеивал бы её со следующим эмодзи.
+func emojiBeforeZWJ(cluster []rune) bool {
+	i := len(cluster) - 1
+	for i >= 0 && isExtend(cluster[i]) {
+		i--
+	}
+	return i >= 0 && isPictographic(cluster[i])
+}
+
+// isRegionalIndicator буквы региональных индикаторов, парами дающие флаг
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// regionalRun считает региональные индикаторы в конце кластера
+func regionalRun(cluster []rune) int {
+	n := 0
+	for i := len(cluster) - 1; i >= 0 && isRegionalIndicator(cluster[i]); i-- {
+		n++
+	}
+	return n
+}
+
+// Классы чамо и слогов хангыля
+const (
+	hangulNone = iota
+	hangulL    // начальная согласная
+	hangulV    // гласная
+	hangulT    // конечная согласная
+	hangulLV   // слог без конечной
+	hangulLVT  // слог с конечной
+)
+
+// hangulType определяет класс руны хангыля
+func hangulType(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return hangulL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return hangulV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return hangulT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+// hangulJoins правила GB6–GB8: чамо собираются в один слог
+func hangulJoins(prev, next rune) bool {
+	p, n := hangulType(prev), hangulType(next)
+	switch p {
+	case hangulL:
+		return n == hangulL || n == hangulV || n == hangulLV || n == hangulLVT
+	case hangulLV, hangulV:
+		return n == hangulV || n == hangulT
+	case hangulLVT, hangulT:
+		return n == hangulT
+	}
+	return false
+}
+
+// prepend класс Prepend: знаки, которые присоединяются к следующей руне
+var prepend = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0600, 0x0605, 1},
+		{0x06DD, 0x070F, 0x070F - 0x06DD},
+		{0x0890, 0x0891, 1},
+		{0x08E2, 0x0D4E, 0x0D4E - 0x08E2},
+	},
+	R32: []unicode.Range32{
+		{0x110BD, 0x110CD, 0x10},
+		{0x111C2, 0x111C3, 1},
+		{0x1193F, 0x11941, 2},
+		{0x11A3A, 0x11A3A, 1},
+		{0x11A84, 0x11A89, 1},
+		{0x11D46, 0x11D46, 1},
+		{0x11F02, 0x11F02, 1},
+	},
+}
+
+// extendedPictographic свойство Extended_Pictographic из emoji-data.txt
+var extendedPictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00A9, 0x00AE, 5},
+		{0x203C, 0x2049, 0x2049 - 0x203C},
+		{0x2122, 0x2139, 0x2139 - 0x2122},
+		{0x2194, 0x2199, 1},
+		{0x21A9, 0x21AA, 1},
+		{0x231A, 0x231B, 1},
+		{0x2328, 0x2388, 0x2388 - 0x2328},
+		{0x23CF, 0x23CF, 1},
+		{0x23E9, 0x23F3, 1},
+		{0x23F8, 0x23FA, 1},
+		{0x24C2, 0x24C2, 1},
+		{0x25AA, 0x25AB, 1},
+		{0x25B6, 0x25C0, 0x25C0 - 0x25B6},
+		{0x25FB, 0x25FE, 1},
+		{0x2600, 0x2605, 1},
+		{0x2607, 0x2612, 1},
+		{0x2614, 0x2685, 1},
+		{0x2690, 0x2705, 1},
+		{0x2708, 0x2712, 1},
+		{0x2714, 0x2716, 2},
+		{0x271D, 0x2721, 4},
+		{0x2728, 0x2728, 1},
+		{0x2733, 0x2734, 1},
+		{0x2744, 0x2747, 3},
+		{0x274C, 0x274E, 2},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2763, 0x2767, 1},
+		{0x2795, 0x2797, 1},
+		{0x27A1, 0x27B0, 0x27B0 - 0x27A1},
+		{0x27BF, 0x27BF, 1},
+		{0x2934, 0x2935, 1},
+		{0x2B05, 0x2B07, 1},
+		{0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B55, 5},
+		{0x3030, 0x303D, 0x303D - 0x3030},
+		{0x3297, 0x3299, 2},
+	},
+	R32: []unicode.Range32{
+		{0x1F000, 0x1F0FF, 1},
+		{0x1F10D, 0x1F10F, 1},
+		{0x1F12F, 0x1F12F, 1},
+		{0x1F16C, 0x1F171, 1},
+		{0x1F17E, 0x1F17F, 1},
+		{0x1F18E, 0x1F18E, 1},
+		{0x1F191, 0x1F19A, 1},
+		{0x1F1AD, 0x1F1E5, 1},
+		{0x1F201, 0x1F20F, 1},
+		{0x1F21A, 0x1F22F, 0x1F22F - 0x1F21A},
+		{0x1F232, 0x1F23A, 1},
+		{0x1F23C, 0x1F23F, 1},
+		{0x1F249, 0x1F3FA, 1},
+		{0x1F400, 0x1F53D, 1},
+		{0x1F546, 0x1F64F, 1},
+		{0x1F680, 0x1F6FF, 1},
+		{0x1F774, 0x1F77F, 1},
+		{0x1F7D5, 0x1F7FF, 1},
+		{0x1F80C, 0x1F80F, 1},
+		{0x1F848, 0x1F84F, 1},
+		{0x1F85A, 0x1F85F, 1},
+		{0x1F888, 0x1F88F, 1},
+		{0x1F8AE, 0x1F8FF, 1},
+		{0x1F90C, 0x1F93A, 1},
+		{0x1F93C, 0x1F945, 1},
+		{0x1F947, 0x1FAFF, 1},
+		{0x1FC00, 0x1FFFD, 1},
+	},
+	LatinOffset: 1,
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// Расширенный синтаксис (Options.Groups): подстрока в скобках повторяется
+// целиком, группы вложенные. Грамматика разбирается рекурсивным спуском:
+//
+//	sequence = { item }
+//	item     = atom [ count ]
+//	atom     = "(" sequence ")" | "\" rune | rune
+//
+// Скобки, как и цифры, экранируются обратным слэшем.
+
+// DefaultMaxDepth предел вложенности групп по умолчанию
+const DefaultMaxDepth = 64
+
+// node элемент разобранного входа: руна (кластер) или группа с числом повторений
+type node struct {
+	offset   int64   // смещение начала элемента во входе
+	first    rune    // первая руна элемента, для ошибок
+	unit     []byte  // повторяемая руна или кластер; nil у группы
+	children []*node // содержимое группы
+	count    int
+	size     int64 // размер результата элемента, не больше math.MaxInt64
+}
+
+// runGroups распаковывает вход в расширенном синтаксисе. Элементы верхнего
+// уровня выводятся по мере разбора, в памяти держится только текущая группа.
+func (u *unpacker) runGroups() error {
+	for {
+		n, err := u.parseItem(0)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if u.limit >= 0 && n.size > u.limit-u.written {
+			return &UnpackError{Offset: n.offset, Rune: n.first, Kind: ErrOutputTooLarge}
+		}
+		if err := u.emit(n); err != nil {
+			return err
+		}
+		u.written += n.size
+	}
+}
+
+// parseItem разбирает один элемент с числом повторений; depth — число
+// открытых групп. В конце входа возвращает io.EOF.
+func (u *unpacker) parseItem(depth int) (*node, error) {
+	offset := u.offset
+	r, err := u.readRune()
+	if err != nil {
+		return nil, err
+	}
+	n := &node{offset: offset, first: r}
+
+	switch {
+	case r == '(':
+		if u.maxDepth >= 0 && depth >= u.maxDepth {
+			return nil, &UnpackError{Offset: offset, Rune: r, Kind: ErrNestingTooDeep}
+		}
+		if n.children, err = u.parseGroup(depth+1, offset); err != nil {
+			return nil, err
+		}
+	case r == ')':
+		// Закрывающие скобки своих групп забирает parseGroup
+		return nil, &UnpackError{Offset: offset, Rune: r, Kind: ErrUnmatchedParen}
+	case u.isDigit(r):
+		// Цифра без руны перед ней: в начале входа или группы
+		return nil, &UnpackError{Offset: offset, Rune: r, Kind: ErrLeadingDigit}
+	default:
+		if r == '\\' {
+			if r, err = u.readRune(); err == io.EOF {
+				return nil, &UnpackError{Offset: offset, Rune: '\\', Kind: ErrTrailingBackslash}
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		u.unit = utf8.AppendRune(u.unit[:0], r)
+		if u.graphemes {
+			if err := u.readCluster(r); err != nil {
+				return nil, err
+			}
+		}
+		n.unit = append([]byte(nil), u.unit...)
+	}
+
+	if n.count, err = u.readCount(); err != nil {
+		return nil, err
+	}
+	n.size = n.measure()
+	return n, nil
+}
+
+// parseGroup разбирает содержимое группы до закрывающей скобки;
+// open — смещение открывающей скобки для ошибки
+func (u *unpacker) parseGroup(depth int, open int64) ([]*node, error) {
+	var children []*node
+	for {
+		offset := u.offset
+		r, err := u.readRune()
+		if err == io.EOF {
+			return nil, &UnpackError{Offset: open, Rune: '(', Kind: ErrUnclosedGroup}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r == ')' {
+			return children, nil
+		}
+		if err := u.in.UnreadRune(); err != nil {
+			return nil, err
+		}
+		u.offset = offset
+
+		child, err := u.parseItem(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+}
+
+// measure считает размер результата элемента с насыщением на math.MaxInt64,
+// чтобы предел MaxOutput проверялся до вывода
+func (n *node) measure() int64 {
+	unit := int64(len(n.unit))
+	for _, child := range n.children {
+		if child.size > math.MaxInt64-unit {
+			return math.MaxInt64
+		}
+		unit += child.size
+	}
+	if unit != 0 && int64(n.count) > math.MaxInt64/unit {
+		return math.MaxInt64
+	}
+	return unit * int64(n.count)
+}
+
+// emit выводит элемент. Пустые элементы пропускаются, поэтому число
+// итераций не превышает размера результата даже для "(()9)999999999".
+func (u *unpacker) emit(n *node) error {
+	if n.size == 0 {
+		return nil
+	}
+	for j := 0; j < n.count; j++ {
+		if n.unit != nil {
+			if _, err := u.out.Write(n.unit); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, child := range n.children {
+			if err := u.emit(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -2,10 +2,12 @@ module github.com/ZnNr/wb-level2
 
 go 1.25.0
 
-require github.com/beevik/ntp v1.5.0
-
 require (
-	golang.org/x/net v0.47.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
-	golang.org/x/text v0.31.0 // indirect
+	github.com/andybalholm/brotli v1.2.0
+	github.com/beevik/ntp v1.5.0
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/net v0.47.0
+	golang.org/x/text v0.31.0
 )
+
+require golang.org/x/sys v0.38.0 // indirect